@@ -0,0 +1,505 @@
+package cmdz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mxbossard/utilz/cachz"
+	"github.com/mxbossard/utilz/inoutz"
+	"github.com/mxbossard/utilz/printz"
+	"github.com/mxbossard/utilz/promiz"
+	"github.com/mxbossard/utilz/truzt"
+)
+
+type (
+	// Result of a successful execution kept in cache.
+	cachedResult struct {
+		Rc     int    `json:"rc"`
+		Stdout string `json:"stdout"`
+		Stderr string `json:"stderr"`
+	}
+
+	cachedExec struct {
+		Executer
+		cache          cachz.Cache[string]
+		envKeys        []string
+		inputs         []string
+		fallbackConfig *config
+
+		key       string
+		hit       *cachedResult
+		startTime time.Time
+		duration  time.Duration
+	}
+)
+
+// EnvKeys add environment variables whose values take part in the cache key.
+func (c *cachedExec) EnvKeys(keys ...string) *cachedExec {
+	c.envKeys = append(c.envKeys, keys...)
+	return c
+}
+
+// Inputs add files or directories whose contents take part in the cache key.
+func (c *cachedExec) Inputs(paths ...string) *cachedExec {
+	c.inputs = append(c.inputs, paths...)
+	return c
+}
+
+// CacheHit return true if last run was replayed from cache.
+func (c *cachedExec) CacheHit() bool {
+	return c.hit != nil
+}
+
+// CacheKey return the key of last run.
+func (c *cachedExec) CacheKey() string {
+	return c.key
+}
+
+// Value of an environment variable as seen by the executer: env added to the command override the current process env.
+func envValue(e Executer, key string) string {
+	if cmd, ok := e.(*cmdz); ok {
+		for i := len(cmd.environ) - 1; i >= 0; i-- {
+			if k, v, found := strings.Cut(cmd.environ[i], "="); found && k == key {
+				return v
+			}
+		}
+	}
+	return os.Getenv(key)
+}
+
+// Unredacted view of an executer, secrets are kept so they must never be printed.
+type rawer interface {
+	// argv of all commands in execution order
+	rawArgv() []string
+	// Full outputs of last run, complete is false if they were truncated
+	rawRecords() (stdout, stderr string, complete bool)
+}
+
+func rawArgv(e Executer) []string {
+	if r, ok := e.(rawer); ok {
+		return r.rawArgv()
+	}
+	return []string{e.String()}
+}
+
+func rawRecords(e Executer) (stdout, stderr string, complete bool) {
+	if r, ok := e.(rawer); ok {
+		return r.rawRecords()
+	}
+	return "", "", false
+}
+
+func (e *cmdz) rawArgv() []string {
+	return []string{QuoteAll(append([]string{e.binary}, e.args...)...)}
+}
+
+// Read a record in full from its spill file if it was elided.
+func fullRecord(record *inoutz.Record, spillFile string) (string, bool) {
+	if record.Elided() == 0 {
+		return record.String(), true
+	}
+	if spillFile == "" {
+		return "", false
+	}
+	content, err := os.ReadFile(spillFile)
+	if err != nil {
+		return "", false
+	}
+	return string(content), true
+}
+
+func (e *cmdz) rawRecords() (stdout, stderr string, complete bool) {
+	stdout, outComplete := fullRecord(&e.stdoutRecord.Record, e.stdoutFile)
+	stderr, errComplete := fullRecord(&e.stderrRecord.Record, e.stderrFile)
+	return stdout, stderr, outComplete && errComplete
+}
+
+func (s *seq) rawArgv() (argv []string) {
+	for _, e := range s.execs {
+		argv = append(argv, rawArgv(e)...)
+	}
+	return
+}
+
+func (s *seq) rawRecords() (stdout, stderr string, complete bool) {
+	complete = true
+	for _, e := range s.outers {
+		out, err, ok := rawRecords(e)
+		stdout += out
+		stderr += err
+		complete = complete && ok
+	}
+	return
+}
+
+func (e *basicPipe) rawArgv() []string {
+	return append(rawArgv(e.feeder), rawArgv(e.Executer)...)
+}
+
+func (e *basicPipe) rawRecords() (stdout, stderr string, complete bool) {
+	return rawRecords(e.Executer)
+}
+
+func (c *cachedExec) rawArgv() []string {
+	return rawArgv(c.Executer)
+}
+
+func (c *cachedExec) rawRecords() (stdout, stderr string, complete bool) {
+	if c.hit != nil {
+		return c.hit.Stdout, c.hit.Stderr, true
+	}
+	return rawRecords(c.Executer)
+}
+
+func (c *cachedExec) secrets() []string {
+	own := c.Executer.getConfig()
+	return mergeConfigs(&own, c.fallbackConfig).secrets
+}
+
+// Sign argv before redaction, working dir, selected env values and input contents.
+func (c *cachedExec) cacheKey(cfg *config) (string, error) {
+	parts := []string{"argv:" + c.Executer.String(), "dir:" + cfg.dir}
+	for _, argv := range rawArgv(c.Executer) {
+		parts = append(parts, "raw:"+argv)
+	}
+	for _, k := range c.envKeys {
+		parts = append(parts, "env:"+k+"="+envValue(c.Executer, k))
+	}
+	if len(c.inputs) > 0 {
+		sign, err := truzt.SignFsContents(c.inputs...)
+		if err != nil {
+			return "", fmt.Errorf("Unable to sign cache inputs ! Caused by: %w", err)
+		}
+		parts = append(parts, "inputs:"+sign)
+	}
+	return truzt.SignStrings(parts...)
+}
+
+func (c *cachedExec) load(key string) (*cachedResult, error) {
+	value, ok, err := c.cache.Load(key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var res cachedResult
+	if err = json.Unmarshal([]byte(value), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *cachedExec) store(key string, res cachedResult) error {
+	value, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return c.cache.Store(key, string(value))
+}
+
+// Write cached outputs into configured outputs.
+func (c *cachedExec) replay(cfg *config, res *cachedResult) {
+	if cfg.stdout != nil {
+		_, _ = cfg.stdout.Write([]byte(res.Stdout))
+	}
+	if cfg.stderr != nil {
+		_, _ = cfg.stderr.Write([]byte(res.Stderr))
+	}
+}
+
+// BlockRun replay the cached result if any, else run the executer and cache its result if it succeeded.
+func (c *cachedExec) BlockRun() (rc int, err error) {
+	return c.blockRunCtx(nil)
+}
+
+func (c *cachedExec) blockRunCtx(ctx context.Context) (rc int, err error) {
+	c.reset()
+	own := c.Executer.getConfig()
+	cfg := mergeConfigs(&own, c.fallbackConfig)
+	if cfg.dryRun != nil {
+		return c.Executer.blockRunCtx(ctx)
+	}
+
+	c.startTime = time.Now()
+	c.key, err = c.cacheKey(cfg)
+	if err != nil {
+		return -1, err
+	}
+	c.hit, err = c.load(c.key)
+	if err != nil {
+		return -1, fmt.Errorf("Unable to load cached result ! Caused by: %w", err)
+	}
+	if c.hit != nil {
+		c.replay(cfg, c.hit)
+		c.duration = time.Since(c.startTime)
+		return c.hit.Rc, nil
+	}
+
+	rc, err = c.Executer.blockRunCtx(ctx)
+	if err != nil || rc != 0 {
+		return
+	}
+	// Truncated outputs cannot be replayed
+	if stdout, stderr, complete := rawRecords(c.Executer); complete {
+		res := cachedResult{Rc: rc, Stdout: stdout, Stderr: stderr}
+		if err = c.store(c.key, res); err != nil {
+			return -1, fmt.Errorf("Unable to store result in cache ! Caused by: %w", err)
+		}
+	}
+	return
+}
+
+func (c *cachedExec) AsyncRun() *execPromise {
+	return promiz.New(func(resolve func(int), reject func(error)) {
+		rc, err := c.BlockRun()
+		if err != nil {
+			reject(err)
+		}
+		resolve(rc)
+	})
+}
+
+func (c *cachedExec) reset() {
+	c.key = ""
+	c.hit = nil
+	c.startTime = time.Time{}
+	c.duration = 0
+	c.Executer.reset()
+}
+
+func (c *cachedExec) fallback(cfg *config) {
+	c.fallbackConfig = cfg
+	c.Executer.fallback(cfg)
+}
+
+func (c *cachedExec) report() ExecutionReport {
+	r := c.Executer.report()
+	if c.hit != nil {
+		r.Cached = true
+		r.Rc = c.hit.Rc
+		r.Start = c.startTime
+		r.Duration = c.duration
+		r.Stdout = truncateOutput(redact(c.hit.Stdout, c.secrets()))
+		r.Stderr = truncateOutput(redact(c.hit.Stderr, c.secrets()))
+	}
+	return r
+}
+
+// ----- Recorder methods -----
+func (c *cachedExec) StdoutRecord() string {
+	if c.hit != nil {
+		return redact(c.hit.Stdout, c.secrets())
+	}
+	return c.Executer.StdoutRecord()
+}
+
+func (c *cachedExec) StderrRecord() string {
+	if c.hit != nil {
+		return redact(c.hit.Stderr, c.secrets())
+	}
+	return c.Executer.StderrRecord()
+}
+
+// ----- Runner methods -----
+func (c *cachedExec) ResultCodes() []int {
+	if c.hit != nil {
+		return []int{c.hit.Rc}
+	}
+	return c.Executer.ResultCodes()
+}
+
+func (c *cachedExec) ExitCode() int {
+	if c.hit != nil {
+		return c.hit.Rc
+	}
+	return c.Executer.ExitCode()
+}
+
+func (c *cachedExec) StartTimes() []time.Time {
+	if c.hit != nil {
+		return []time.Time{c.startTime}
+	}
+	return c.Executer.StartTimes()
+}
+
+func (c *cachedExec) StartTime() time.Time {
+	if c.hit != nil {
+		return c.startTime
+	}
+	return c.Executer.StartTime()
+}
+
+func (c *cachedExec) Durations() []time.Duration {
+	if c.hit != nil {
+		return []time.Duration{c.duration}
+	}
+	return c.Executer.Durations()
+}
+
+func (c *cachedExec) Duration() time.Duration {
+	if c.hit != nil {
+		return c.duration
+	}
+	return c.Executer.Duration()
+}
+
+// ----- Override InOuter methods -----
+func (c *cachedExec) SetInput(stdin io.Reader) Executer {
+	c.Executer = c.Executer.SetInput(stdin)
+	return c
+}
+
+func (c *cachedExec) SetStdout(stdout io.Writer) Executer {
+	c.Executer = c.Executer.SetStdout(stdout)
+	return c
+}
+
+func (c *cachedExec) SetStderr(stderr io.Writer) Executer {
+	c.Executer = c.Executer.SetStderr(stderr)
+	return c
+}
+
+func (c *cachedExec) SetOutputs(stdout, stderr io.Writer) Executer {
+	c.Executer = c.Executer.SetOutputs(stdout, stderr)
+	return c
+}
+
+// ----- Override Configurer methods -----
+func (c *cachedExec) ErrorOnFailure(ok bool) Executer {
+	c.Executer = c.Executer.ErrorOnFailure(ok)
+	return c
+}
+
+func (c *cachedExec) Retries(count, delayInMs int) Executer {
+	c.Executer = c.Executer.Retries(count, delayInMs)
+	return c
+}
+
+func (c *cachedExec) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	c.Executer = c.Executer.Backoff(factor, maxDelay, jitter)
+	return c
+}
+
+func (c *cachedExec) RetryTimeout(total time.Duration) Executer {
+	c.Executer = c.Executer.RetryTimeout(total)
+	return c
+}
+
+func (c *cachedExec) RetryIf(predicate RetryPredicate) Executer {
+	c.Executer = c.Executer.RetryIf(predicate)
+	return c
+}
+
+func (c *cachedExec) Timeout(duration time.Duration) Executer {
+	c.Executer = c.Executer.Timeout(duration)
+	return c
+}
+
+func (c *cachedExec) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	c.Executer = c.Executer.Termination(signal, grace)
+	return c
+}
+
+func (c *cachedExec) Context(ctx context.Context) Executer {
+	c.Executer = c.Executer.Context(ctx)
+	return c
+}
+
+func (c *cachedExec) Dir(path string) Executer {
+	c.Executer = c.Executer.Dir(path)
+	return c
+}
+
+func (c *cachedExec) Nice(n int) Executer {
+	c.Executer = c.Executer.Nice(n)
+	return c
+}
+
+func (c *cachedExec) Umask(mask os.FileMode) Executer {
+	c.Executer = c.Executer.Umask(mask)
+	return c
+}
+
+func (c *cachedExec) Limits(limits ResourceLimits) Executer {
+	c.Executer = c.Executer.Limits(limits)
+	return c
+}
+
+func (c *cachedExec) InheritEnv(allowed ...string) Executer {
+	c.Executer = c.Executer.InheritEnv(allowed...)
+	return c
+}
+
+func (c *cachedExec) CleanEnv() Executer {
+	c.Executer = c.Executer.CleanEnv()
+	return c
+}
+
+func (c *cachedExec) Secrets(values ...string) Executer {
+	c.Executer = c.Executer.Secrets(values...)
+	return c
+}
+
+func (c *cachedExec) Hooks(hooks ...Hooks) Executer {
+	c.Executer = c.Executer.Hooks(hooks...)
+	return c
+}
+
+func (c *cachedExec) DryRun(printer printz.Printer) Executer {
+	c.Executer = c.Executer.DryRun(printer)
+	return c
+}
+
+func (c *cachedExec) DryRunRc(rc int) Executer {
+	c.Executer = c.Executer.DryRunRc(rc)
+	return c
+}
+
+func (c *cachedExec) RecordLimit(maxBytes int) Executer {
+	c.Executer = c.Executer.RecordLimit(maxBytes)
+	return c
+}
+
+func (c *cachedExec) SpillRecords() Executer {
+	c.Executer = c.Executer.SpillRecords()
+	return c
+}
+
+func (c *cachedExec) CombinedOutputs() Executer {
+	c.Executer = c.Executer.CombinedOutputs()
+	return c
+}
+
+func (c *cachedExec) Mock(m *Mock) Executer {
+	c.Executer = c.Executer.Mock(m)
+	return c
+}
+
+func (c *cachedExec) Record(r *Recording) Executer {
+	c.Executer = c.Executer.Record(r)
+	return c
+}
+
+func (c *cachedExec) InProcess(fn ProcessFunc) Executer {
+	c.Executer = c.Executer.InProcess(fn)
+	return c
+}
+
+func (c *cachedExec) AddEnv(key, value string) Executer {
+	c.Executer = c.Executer.AddEnv(key, value)
+	return c
+}
+
+func (c *cachedExec) AddEnviron(environ ...string) Executer {
+	c.Executer = c.Executer.AddEnviron(environ...)
+	return c
+}
+
+func (c *cachedExec) AddArgs(args ...string) Executer {
+	c.Executer = c.Executer.AddArgs(args...)
+	return c
+}
@@ -0,0 +1,186 @@
+package cmdz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mxbossard/utilz/cachz"
+	"github.com/mxbossard/utilz/printz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCache(t *testing.T) cachz.Cache[string] {
+	cache, err := cachz.NewPersistentCache[string](t.TempDir())
+	require.NoError(t, err)
+	return cache
+}
+
+func TestCached(t *testing.T) {
+	cache := newTestCache(t)
+	counter := filepath.Join(t.TempDir(), "counter")
+	run := func() *cachedExec {
+		return Cached(Sh("echo run >> "+counter+"; echo foo; >&2 echo bar"), cache)
+	}
+
+	c := run()
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.False(t, c.CacheHit())
+	assert.NotEmpty(t, c.CacheKey())
+	assert.Equal(t, "foo\n", c.StdoutRecord())
+	assert.False(t, Report(c).Cached)
+
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+	c = run()
+	c.SetOutputs(&stdout, &stderr)
+	rc, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.True(t, c.CacheHit())
+	assert.Equal(t, "foo\n", c.StdoutRecord())
+	assert.Equal(t, "bar\n", c.StderrRecord())
+	assert.Equal(t, "foo\n", stdout.String())
+	assert.Equal(t, "bar\n", stderr.String())
+	assert.Equal(t, []int{0}, c.ResultCodes())
+
+	r := Report(c)
+	assert.True(t, r.Cached)
+	assert.Equal(t, 0, r.Rc)
+	assert.Equal(t, "foo\n", r.Stdout)
+	assert.False(t, r.Start.IsZero())
+
+	// Cache hit inside a sequence
+	s := Serial(run())
+	rc, err = s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	r = Report(s)
+	require.Len(t, r.Children, 1)
+	assert.True(t, r.Children[0].Cached)
+
+	content, err := os.ReadFile(counter)
+	require.NoError(t, err)
+	assert.Equal(t, "run\n", string(content))
+}
+
+func TestCached_Failure(t *testing.T) {
+	cache := newTestCache(t)
+	c := Cached(Cmd("false"), cache)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+
+	rc, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.False(t, c.CacheHit())
+}
+
+func TestCached_Key(t *testing.T) {
+	cache := newTestCache(t)
+	input := filepath.Join(t.TempDir(), "input")
+	require.NoError(t, os.WriteFile(input, []byte("a"), 0644))
+	key := func(e Executer) string {
+		c := Cached(e, cache).EnvKeys("CACHED_VAR").Inputs(input)
+		_, err := c.BlockRun()
+		require.NoError(t, err)
+		return c.CacheKey()
+	}
+
+	initial := key(Cmd("true"))
+	assert.Equal(t, initial, key(Cmd("true")))
+	assert.NotEqual(t, initial, key(Cmd("true", "foo")))
+	assert.NotEqual(t, initial, key(Cmd("true").AddEnv("CACHED_VAR", "foo")))
+	assert.Equal(t, initial, key(Cmd("true").AddEnv("OTHER_VAR", "foo")))
+	assert.NotEqual(t, initial, key(Cmd("true").Dir(os.TempDir())))
+
+	require.NoError(t, os.WriteFile(input, []byte("b"), 0644))
+	assert.NotEqual(t, initial, key(Cmd("true")))
+
+	_, err := Cached(Cmd("true"), cache).Inputs("/not/existing").BlockRun()
+	assert.Error(t, err)
+}
+
+func TestCached_Configurer(t *testing.T) {
+	cache := newTestCache(t)
+	c := Cached(Cmd("echo", "foo"), cache)
+	e := c.AddArgs("bar").Retries(1, 0)
+	assert.Same(t, c, e)
+	_, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "foo bar\n", e.StdoutRecord())
+
+	// Dry-run bypass the cache
+	outW := &strings.Builder{}
+	errW := &strings.Builder{}
+	d := Cached(Cmd("echo", "foo"), cache).DryRun(printz.New(printz.NewOutputs(outW, errW)))
+	_, err = d.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "echo foo\n", outW.String())
+	c = Cached(Cmd("echo", "foo"), cache)
+	_, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.False(t, c.CacheHit())
+}
+
+func TestCached_Secrets(t *testing.T) {
+	cache := newTestCache(t)
+	run := func(secret string) *cachedExec {
+		c := Cached(Cmd("echo", "token", secret).Secrets("aaa", "bbb"), cache)
+		_, err := c.BlockRun()
+		require.NoError(t, err)
+		return c
+	}
+
+	// Commands differing only by a secret do not share their results
+	a := run("aaa")
+	b := run("bbb")
+	assert.NotEqual(t, a.CacheKey(), b.CacheKey())
+	assert.False(t, b.CacheHit())
+
+	// Hit replay the real output in outputs and keep records redacted
+	out := &strings.Builder{}
+	c := Cached(Cmd("echo", "token", "aaa").Secrets("aaa", "bbb"), cache)
+	c.SetStdout(out)
+	_, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.True(t, c.CacheHit())
+	assert.Equal(t, "token aaa\n", out.String())
+	assert.Equal(t, "token "+redactedSecret+"\n", c.StdoutRecord())
+}
+
+func TestCached_Truncated(t *testing.T) {
+	cache := newTestCache(t)
+	run := func() *cachedExec {
+		c := Cached(Sh("seq 1 1000"), cache)
+		c.RecordLimit(64)
+		_, err := c.BlockRun()
+		require.NoError(t, err)
+		return c
+	}
+	run()
+	// Truncated outputs are not cached
+	assert.False(t, run().CacheHit())
+
+	spill := func() *cachedExec {
+		c := Cached(Sh("seq 1 1000"), cache)
+		c.RecordLimit(64).SpillRecords()
+		_, err := c.BlockRun()
+		require.NoError(t, err)
+		return c
+	}
+	spill()
+	// Spilled outputs are cached in full
+	out := &strings.Builder{}
+	c := Cached(Sh("seq 1 1000"), cache)
+	c.RecordLimit(64).SpillRecords().SetStdout(out)
+	_, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.True(t, c.CacheHit())
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), 1000)
+}
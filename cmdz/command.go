@@ -5,16 +5,22 @@ import (
 
 	"bytes"
 	"context"
+	"errors"
 	"log"
 
-	//"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/mxbossard/utilz/errorz"
+	"github.com/mxbossard/utilz/filez"
 	"github.com/mxbossard/utilz/inoutz"
+	"github.com/mxbossard/utilz/printz"
 	"github.com/mxbossard/utilz/promiz"
 	"github.com/mxbossard/utilz/ztring"
 )
@@ -32,15 +38,59 @@ func (f failure) Error() (msg string) {
 	return
 }
 
+// timeoutFailure is returned when an execution was terminated because it exceeded its timeout.
+// It wraps an errorz timeout, so it can be distinguished from a failure with errorz.IsTimeout().
+type timeoutFailure struct {
+	cause    error
+	reporter Reporter
+}
+
+func (f timeoutFailure) Error() (msg string) {
+	msg = f.cause.Error()
+	if f.reporter != nil {
+		stderrSummary := ztring.SummaryRatio(f.reporter.ReportError(), 128, 0.2)
+		msg = fmt.Sprintf("%s ! stderr: %s", msg, stderrSummary)
+	}
+	return
+}
+
+func (f timeoutFailure) Unwrap() error {
+	return f.cause
+}
+
 type config struct {
-	retries        int
-	retryDelayInMs int
-	timeout        time.Duration
-	stdin          io.Reader
-	stdout         io.Writer
-	stderr         io.Writer
-	combinedOuts   bool
-	errorOnFailure bool
+	retries          int
+	retryDelayInMs   int
+	retryFactor      float64
+	retryMaxDelay    time.Duration
+	retryJitter      float64
+	retryMaxDuration time.Duration
+	retryPredicate   RetryPredicate
+	mock             *Mock
+	inProcess        ProcessFunc
+	recording        *Recording
+	timeout          time.Duration
+	termSignal       syscall.Signal
+	termGrace        time.Duration
+	ctx              context.Context
+	dir              string
+	nice             int
+	umask            *os.FileMode
+	limits           ResourceLimits
+	envMode          envMode
+	envAllowed       []string
+	secrets          []string
+	hooks            []Hooks
+	dryRun           printz.Printer
+	dryRunRc         *int
+	dryRunDepth      int
+	recordLimit      int
+	spillRecords     bool
+	stdin            io.Reader
+	stdout           io.Writer
+	stderr           io.Writer
+	combinedOuts     bool
+	errorOnFailure   bool
 
 	feeder      *cmdz
 	pipedInput  bool
@@ -60,9 +110,75 @@ func mergeConfigs(higher, lower *config) *config {
 	if merged.retryDelayInMs == 0 {
 		merged.retryDelayInMs = lower.retryDelayInMs
 	}
+	if merged.retryFactor == 0 {
+		merged.retryFactor = lower.retryFactor
+	}
+	if merged.retryMaxDelay == 0 {
+		merged.retryMaxDelay = lower.retryMaxDelay
+	}
+	if merged.retryJitter == 0 {
+		merged.retryJitter = lower.retryJitter
+	}
+	if merged.retryMaxDuration == 0 {
+		merged.retryMaxDuration = lower.retryMaxDuration
+	}
+	if merged.retryPredicate == nil {
+		merged.retryPredicate = lower.retryPredicate
+	}
+	if merged.mock == nil {
+		merged.mock = lower.mock
+	}
+	if merged.inProcess == nil {
+		merged.inProcess = lower.inProcess
+	}
+	if merged.recording == nil {
+		merged.recording = lower.recording
+	}
 	if merged.timeout == 0 {
 		merged.timeout = lower.timeout
 	}
+	if merged.termSignal == 0 {
+		merged.termSignal = lower.termSignal
+	}
+	if merged.termGrace == 0 {
+		merged.termGrace = lower.termGrace
+	}
+	if merged.ctx == nil {
+		merged.ctx = lower.ctx
+	}
+	if merged.dir == "" {
+		merged.dir = lower.dir
+	}
+	if merged.nice == 0 {
+		merged.nice = lower.nice
+	}
+	if merged.umask == nil {
+		merged.umask = lower.umask
+	}
+	merged.limits = mergeLimits(merged.limits, lower.limits)
+	if merged.envMode == envUnset {
+		merged.envMode = lower.envMode
+		merged.envAllowed = lower.envAllowed
+	}
+	if merged.dryRun == nil {
+		merged.dryRun = lower.dryRun
+	}
+	if merged.dryRunRc == nil {
+		merged.dryRunRc = lower.dryRunRc
+	}
+	merged.dryRunDepth = lower.dryRunDepth
+	if merged.recordLimit == 0 {
+		merged.recordLimit = lower.recordLimit
+	}
+	if !merged.spillRecords {
+		merged.spillRecords = lower.spillRecords
+	}
+	if len(lower.hooks) > 0 {
+		merged.hooks = append(append([]Hooks(nil), merged.hooks...), lower.hooks...)
+	}
+	if len(lower.secrets) > 0 {
+		merged.secrets = append(append([]string(nil), merged.secrets...), lower.secrets...)
+	}
 	if merged.stdout == nil {
 		merged.stdout = lower.stdout
 	}
@@ -76,36 +192,47 @@ func mergeConfigs(higher, lower *config) *config {
 }
 
 type cmdz struct {
-	cmd    *exec.Cmd
-	ctx    context.Context
-	cancel context.CancelFunc
+	cmd *exec.Cmd
+	ctx context.Context
 
 	binary      string
 	args        []string
 	environ     []string
 	initialized bool
-	stdin       io.Reader
-	stdout      io.Writer
-	stderr      io.Writer
+	// Error returned by runs instead of executing the command
+	invalid error
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
 
 	config
 
 	cmdCheckpoint  exec.Cmd
 	fallbackConfig *config
 
-	stdinRecord  inoutz.RecordingReader
-	stdoutRecord inoutz.RecordingWriter
-	stderrRecord inoutz.RecordingWriter
+	stdinRecord  inoutz.CappedRecordingReader
+	stdoutRecord inoutz.CappedRecordingWriter
+	stderrRecord inoutz.CappedRecordingWriter
+	stdoutFile   string
+	stderrFile   string
 
 	inProcesser  inoutz.ProcessingReader
 	outProcesser inoutz.ProcessingWriter
 	errProcesser inoutz.ProcessingWriter
 
+	pty *PtyOptions
+	// Copy stdin without Wait waiting for the copy, so a blocking interactive input cannot block Wait.
+	asyncInput bool
+
+	stdoutLines *inoutz.CallbackLineWriter
+	stderrLines *inoutz.CallbackLineWriter
+
 	exitCodes []int
 	// FIXME: replace exitCodes by Executions
 	executions []*exec.Cmd
 	startTimes []time.Time
 	durations  []time.Duration
+	pipeStatus []int
 }
 
 func (e *cmdz) getConfig() config {
@@ -198,6 +325,9 @@ func (e *cmdz) setupStdout(stdout io.Writer) {
 	e.initProcessers()
 	e.config.stdout = stdout
 	e.stdoutRecord.Nested = stdout
+	if e.stdoutLines != nil {
+		e.stdoutRecord.Nested = io.MultiWriter(stdout, e.stdoutLines)
+	}
 	e.outProcesser.Nest(&e.stdoutRecord)
 	e.cmd.Stdout = e.outProcesser
 	if e.config.combinedOuts {
@@ -221,6 +351,9 @@ func (e *cmdz) setupStderr(stderr io.Writer) {
 	e.initProcessers()
 	e.config.stderr = stderr
 	e.stderrRecord.Nested = stderr
+	if e.stderrLines != nil {
+		e.stderrRecord.Nested = io.MultiWriter(stderr, e.stderrLines)
+	}
 	e.errProcesser.Nest(&e.stderrRecord)
 	e.cmd.Stderr = e.errProcesser
 }
@@ -243,6 +376,34 @@ func (e *cmdz) ProcessErr(pcrs ...IOProcesser) *cmdz {
 	return e
 }
 
+// Pty execute the process in a pseudo-terminal. Stdout and stderr are merged in the terminal output.
+func (e *cmdz) Pty(opts PtyOptions) *cmdz {
+	e.pty = &opts
+	return e
+}
+
+// OnStdoutLine call callback with each stdout line as soon as it is written.
+func (e *cmdz) OnStdoutLine(callback func(line string)) *cmdz {
+	e.stdoutLines = &inoutz.CallbackLineWriter{Callback: callback}
+	return e
+}
+
+// OnStderrLine call callback with each stderr line as soon as it is written.
+func (e *cmdz) OnStderrLine(callback func(line string)) *cmdz {
+	e.stderrLines = &inoutz.CallbackLineWriter{Callback: callback}
+	return e
+}
+
+// Call line callbacks with last unterminated lines.
+func (e *cmdz) flushLines() {
+	if e.stdoutLines != nil {
+		_ = e.stdoutLines.Flush()
+	}
+	if e.stderrLines != nil {
+		_ = e.stderrLines.Flush()
+	}
+}
+
 // ----- Configurer methods -----
 func (e *cmdz) ErrorOnFailure(enable bool) Executer {
 	e.config.errorOnFailure = enable
@@ -255,9 +416,123 @@ func (e *cmdz) Retries(count, delayInMs int) Executer {
 	return e
 }
 
+// Backoff make retry delay grow exponentially by factor, capped by maxDelay and randomized by a jitter ratio.
+func (e *cmdz) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	e.config.retryFactor = factor
+	e.config.retryMaxDelay = maxDelay
+	e.config.retryJitter = jitter
+	return e
+}
+
+// RetryTimeout do not start a retry which would exceed the total duration since first attempt.
+func (e *cmdz) RetryTimeout(total time.Duration) Executer {
+	e.config.retryMaxDuration = total
+	return e
+}
+
+// RetryIf retry only failed attempts matching the predicate.
+func (e *cmdz) RetryIf(predicate RetryPredicate) Executer {
+	e.config.retryPredicate = predicate
+	return e
+}
+
 func (e *cmdz) Timeout(duration time.Duration) Executer {
 	e.config.timeout = duration
-	e.ctx, e.cancel = context.WithTimeout(context.Background(), duration)
+	return e
+}
+
+// Termination configure how the process group is terminated on timeout or context cancellation:
+// signal is sent to the whole group, then SIGKILL after grace period.
+func (e *cmdz) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	e.config.termSignal = signal
+	e.config.termGrace = grace
+	return e
+}
+
+// Context cancel the execution when ctx is done: pending attempts are not started and the running process is terminated.
+// Executions canceled return the ctx error.
+func (e *cmdz) Context(ctx context.Context) Executer {
+	e.config.ctx = ctx
+	return e
+}
+
+// Dir set the working directory of the process. Default to current process working directory.
+func (e *cmdz) Dir(path string) Executer {
+	e.config.dir = path
+	return e
+}
+
+// Nice add n to the niceness of the process.
+func (e *cmdz) Nice(n int) Executer {
+	e.config.nice = n
+	return e
+}
+
+// Umask set the file mode creation mask of the process.
+func (e *cmdz) Umask(mask os.FileMode) Executer {
+	e.config.umask = &mask
+	return e
+}
+
+// Limits set resource limits of the process. Limits not set are inherited from sequences.
+func (e *cmdz) Limits(limits ResourceLimits) Executer {
+	e.config.limits = limits
+	return e
+}
+
+// InheritEnv pass the current process environment to the process, only the allowed keys if any.
+// An allowed key ending with * is a prefix. Default to inherit all environment only if no entry was added,
+// else only added entries are passed.
+func (e *cmdz) InheritEnv(allowed ...string) Executer {
+	if len(allowed) == 0 {
+		e.config.envMode = envInheritAll
+	} else {
+		e.config.envMode = envInheritAllowed
+	}
+	e.config.envAllowed = allowed
+	return e
+}
+
+// CleanEnv pass only the added environment to the process.
+func (e *cmdz) CleanEnv() Executer {
+	e.config.envMode = envClean
+	e.config.envAllowed = nil
+	return e
+}
+
+// Secrets mark values to redact from String(), ReportError(), records and reports.
+func (e *cmdz) Secrets(values ...string) Executer {
+	e.config.secrets = append(e.config.secrets, values...)
+	return e
+}
+
+// Hooks observe executions of the command. Hooks are chained with the ones of sequences and the default ones.
+func (e *cmdz) Hooks(hooks ...Hooks) Executer {
+	e.config.hooks = append(e.config.hooks, hooks...)
+	return e
+}
+
+// DryRun print the commands instead of executing them, with printer or standard outputs if nil.
+func (e *cmdz) DryRun(printer printz.Printer) Executer {
+	e.config.dryRun = dryRunPrinter(printer)
+	return e
+}
+
+// DryRunRc simulate the result code of the command in dry-run mode. Default to 0.
+func (e *cmdz) DryRunRc(rc int) Executer {
+	e.config.dryRunRc = &rc
+	return e
+}
+
+// RecordLimit cap the bytes kept by each record, keeping head and tail and eliding the middle. Zero means no limit.
+func (e *cmdz) RecordLimit(maxBytes int) Executer {
+	e.config.recordLimit = maxBytes
+	return e
+}
+
+// SpillRecords write full stdout and stderr of each run into temp files, see StdoutFile() and StderrFile().
+func (e *cmdz) SpillRecords() Executer {
+	e.config.spillRecords = true
 	return e
 }
 
@@ -266,42 +541,61 @@ func (e *cmdz) CombinedOutputs() Executer {
 	return e
 }
 
+// Mock replace executions by playing the calls expected by m.
+func (e *cmdz) Mock(m *Mock) Executer {
+	e.config.mock = m
+	return e
+}
+
+// Record capture each execution attempt in r.
+func (e *cmdz) Record(r *Recording) Executer {
+	e.config.recording = r
+	return e
+}
+
+// InProcess replace executions by fn, which read stdin and write outputs of the command and return its result code.
+// Timeouts and cancellations cancel the ctx given to fn, which should return promptly.
+func (e *cmdz) InProcess(fn ProcessFunc) Executer {
+	e.config.inProcess = fn
+	return e
+}
+
 // ----- Recorder methods -----
 func (e *cmdz) StdinRecord() string {
-	return e.stdinRecord.String()
+	return redact(e.stdinRecord.String(), e.secrets())
 }
 
 func (e *cmdz) StdoutRecord() string {
-	return e.stdoutRecord.String()
+	return redact(e.stdoutRecord.String(), e.secrets())
 }
 
 func (e *cmdz) StderrRecord() string {
-	return e.stderrRecord.String()
+	return redact(e.stderrRecord.String(), e.secrets())
+}
+
+// StdoutFile return the path of the file full stdout of last run was spilled into, or empty if not spilled.
+// Spilled files are not redacted and are removed when the command is run again.
+func (e *cmdz) StdoutFile() string {
+	return e.stdoutFile
+}
+
+// StderrFile return the path of the file full stderr of last run was spilled into, or empty if not spilled.
+// Spilled files are not redacted and are removed when the command is run again.
+func (e *cmdz) StderrFile() string {
+	return e.stderrFile
 }
 
 // ----- Reporter methods -----
-func (e cmdz) String() (t string) {
-	//t = e.binary + " " + strings.Join(e.args, " ")
-	if strings.Contains(e.binary, " ") {
-		t = fmt.Sprintf("\"%s\"", e.binary)
-	} else {
-		t = e.binary
-	}
-	for _, arg := range e.args {
-		if strings.Contains(arg, " ") {
-			t += fmt.Sprintf(" \"%s\"", arg)
-		} else {
-			t += fmt.Sprintf(" %s", arg)
-		}
-	}
-	return
+func (e cmdz) String() string {
+	argv := append([]string{e.binary}, e.args...)
+	return QuoteAll(redactAll(argv, e.secrets())...)
 }
 
 func (e cmdz) ReportError() string {
 	execCmdSummary := e.String()
 	attempts := len(e.exitCodes)
 	status := e.exitCodes[attempts-1]
-	stderr := e.stderrRecord.String()
+	stderr := e.StderrRecord()
 	errorMessage := fmt.Sprintf("Exec failed after %d attempt(s): [%s] !\nRC=%d ERR> %s", attempts, execCmdSummary, status, strings.TrimSpace(stderr))
 	return errorMessage
 }
@@ -315,6 +609,8 @@ func (e *cmdz) reset() {
 	e.executions = nil
 	e.startTimes = nil
 	e.durations = nil
+	e.pipeStatus = nil
+	e.removeSpills()
 	e.rollback()
 }
 
@@ -327,6 +623,7 @@ func (e *cmdz) init() {
 	if !e.initialized {
 		e.cmd = exec.CommandContext(e.ctx, e.binary, e.args...)
 		e.cmd.Env = e.environ
+		setupProcessGroup(e.cmd)
 	}
 	//e.setupStdin(e.stdin)
 	//e.setupStdout(e.stdout)
@@ -335,98 +632,317 @@ func (e *cmdz) init() {
 }
 
 func (e *cmdz) BlockRun() (rc int, err error) {
-	f := e.feeder
-	var originalStdout io.Writer
-	var originalStdin io.Reader
-	if f != nil {
-		originalStdout = f.config.stdout
-		originalStdin = f.config.stdin
-		b := bytes.Buffer{}
-
-		// Replace configured stdin / stdout temporarilly
-		f.pipedOutput = true
-		f.config.stdout = &b
-
-		e.pipedInput = true
-		e.config.stdin = &b
-
-		frc, ferr := e.feeder.BlockRun()
-		if _, ok := ferr.(failure); ferr != nil && !ok {
-			// If feeder error is not a failure{} return it immediately
-			return frc, ferr
-		}
+	return e.blockRunCtx(nil)
+}
 
-		if e.feeder.pipeFail && frc > 0 {
-			// if pipefail enabled and rc > 0 fail shortly
-			return frc, ferr
-		}
+func (e *cmdz) blockRunCtx(ctx context.Context) (rc int, err error) {
+	if e.feeder != nil {
+		return e.blockRunPipeline(ctx)
+	}
+	config := withRunCtx(e.prepare(), ctx)
+	if config.dryRun != nil {
+		return e.dryRun(config)
 	}
+	return e.blockRunAttempts(config, nil)
+}
 
+// Reset and setup the command before running it. Return the merged config to run with.
+func (e *cmdz) prepare() *config {
 	e.reset()
 
 	e.init()
 
 	config := mergeConfigs(&e.config, e.fallbackConfig)
+	e.cmd.Dir = config.dir
+	e.cmd.Env = buildEnviron(config, e.environ)
 	e.setupStdin(config.stdin)
 	e.setupStdout(config.stdout)
 	e.setupStderr(config.stderr)
 	e.checkpoint()
+	return config
+}
 
-	if e.timeout > 0 {
-		defer e.cancel()
-	}
-
+// Run all attempts of a prepared command. started is notified when first attempt started or failed to start.
+func (e *cmdz) blockRunAttempts(config *config, started func(error)) (rc int, err error) {
 	rc = -1
-	for i := 0; i <= config.retries && rc != 0; i++ {
+	e.limitRecords(config.recordLimit)
+	if config.spillRecords {
+		closeSpills, err := e.spillRecords()
+		if err != nil {
+			return -1, err
+		}
+		defer closeSpills()
+	}
+	hooks := hookChain(config)
+	defer func() {
+		if err != nil {
+			onFailure(hooks, e, err)
+		} else if rc != 0 {
+			onFailure(hooks, e, failure{rc, e})
+		}
+	}()
+	if e.invalid != nil {
+		notifyStarted(started, e.invalid)
+		return -1, e.invalid
+	}
+	var firstStart time.Time
+	for i := 0; i <= config.retries; i++ {
 		var startTime time.Time
 		var duration time.Duration
 		if i > 0 {
 			// Wait between retries
-			time.Sleep(time.Duration(config.retryDelayInMs) * time.Millisecond)
-		}
-		if commandMock == nil {
-			startTime = time.Now()
-			e.startTimes = append(e.startTimes, startTime)
-			err = e.cmd.Start()
-			if err != nil {
-				return
-			}
-			err = e.cmd.Wait()
-			duration = time.Since(startTime)
-
-			if e.ctx.Err() != nil {
-				err = e.ctx.Err()
+			delay := retryDelay(config, i)
+			if config.retryMaxDuration > 0 && time.Since(firstStart)+delay > config.retryMaxDuration {
+				// Retry would exceed max retry duration
+				break
 			}
-			if err != nil {
-				if exitErr, ok := err.(*exec.ExitError); ok {
-					rc = exitErr.ProcessState.ExitCode()
-					err = nil
-				} else {
-					return -1, err
-				}
-			} else {
-				rc = e.cmd.ProcessState.ExitCode()
+			select {
+			case <-time.After(delay):
+			case <-done(config.ctx):
 			}
-		} else {
+		}
+		if err = canceled(config.ctx); err != nil {
+			return -1, err
+		}
+		inOffset := e.stdinRecord.Record.Len()
+		outOffset := e.stdoutRecord.Record.Len()
+		errOffset := e.stderrRecord.Record.Len()
+		beforeStart(hooks, e)
+		startTime = time.Now()
+		e.startTimes = append(e.startTimes, startTime)
+		var timedOut bool
+		if config.mock != nil {
+			notifyStarted(started, nil)
+			rc, timedOut, err = config.mock.play(config.ctx, e.cmd, config.timeout, config.secrets)
+		} else if config.inProcess != nil {
+			notifyStarted(started, nil)
+			rc, timedOut, err = e.executeInProcess(config)
+		} else if commandMock != nil {
 			// Replace execution by mocking function
+			notifyStarted(started, nil)
 			rc = commandMock.Mock(e.cmd)
+		} else {
+			rc, timedOut, err = e.execute(config, started)
+		}
+		started = nil
+		duration = time.Since(startTime)
+		e.flushLines()
+		if err != nil {
+			afterAttempt(hooks, e, -1, duration)
+			return -1, err
+		}
+		if timedOut {
+			afterAttempt(hooks, e, -1, duration)
+			e.exitCodes = append(e.exitCodes, -1)
+			e.executions = append(e.executions, e.cmd)
+			e.durations = append(e.durations, duration)
+			cause := errorz.Timeoutf(config.timeout, "executing: [%s]", e.String())
+			return -1, timeoutFailure{cause, e}
+		}
+		if i == 0 {
+			firstStart = startTime
 		}
 		e.exitCodes = append(e.exitCodes, rc)
 		e.executions = append(e.executions, e.cmd)
 		e.durations = append(e.durations, duration)
 		e.rollback()
+		afterAttempt(hooks, e, rc, duration)
+
+		stdout := e.stdoutRecord.Record.Since(outOffset)
+		stderr := e.stderrRecord.Record.Since(errOffset)
+		if config.recording != nil {
+			stdin := e.stdinRecord.Record.Since(inOffset)
+			config.recording.record(e.cmd, stdin, stdout, stderr, rc, duration, config.secrets)
+		}
+		if !retryable(config, rc, stdout, stderr) {
+			break
+		}
 	}
 	if e.errorOnFailure && rc > 0 {
 		err = failure{rc, e}
 		rc = -1
 	}
-	if f != nil {
-		f.config.stdout = originalStdout
-		e.config.stdin = originalStdin
-	}
 	return
 }
 
+func (e *cmdz) limitRecords(maxBytes int) {
+	e.stdinRecord.Record.MaxSize = maxBytes
+	e.stdoutRecord.Record.MaxSize = maxBytes
+	e.stderrRecord.Record.MaxSize = maxBytes
+}
+
+// Spill stdout and stderr records into new temp files. Returned func stop spilling and close the files.
+func (e *cmdz) spillRecords() (func(), error) {
+	stdout, err := createSpill("cmdz-stdout-*.log")
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := createSpill("cmdz-stderr-*.log")
+	if err != nil {
+		_ = stdout.Close()
+		return nil, err
+	}
+	e.stdoutFile = stdout.Name()
+	e.stderrFile = stderr.Name()
+	e.stdoutRecord.Record.Spill = stdout
+	e.stderrRecord.Record.Spill = stderr
+	return func() {
+		e.stdoutRecord.Record.Spill = nil
+		e.stderrRecord.Record.Spill = nil
+		_ = stdout.Close()
+		_ = stderr.Close()
+	}, nil
+}
+
+// Remove the spill files of previous run.
+func (e *cmdz) removeSpills() {
+	for _, path := range []string{e.stdoutFile, e.stderrFile} {
+		if path != "" {
+			_ = os.Remove(path)
+		}
+	}
+	e.stdoutFile = ""
+	e.stderrFile = ""
+}
+
+func createSpill(pattern string) (*os.File, error) {
+	path, err := filez.MkTemp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create spill file ! Caused by: %w", err)
+	}
+	// Spill files are not redacted, only the owner can read them
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create spill file ! Caused by: %w", err)
+	}
+	return f, nil
+}
+
+// Copy stdin into the process from a goroutine not awaited by Wait. The pipe is closed by Wait once the process exited.
+func copyInputAsync(cmd *exec.Cmd) error {
+	stdin := cmd.Stdin
+	cmd.Stdin = nil
+	pipe, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	go func() {
+		_, _ = io.Copy(pipe, stdin)
+		_ = pipe.Close()
+	}()
+	return nil
+}
+
+func notifyStarted(started func(error), err error) {
+	if started != nil {
+		started(err)
+	}
+}
+
+// Execute the process once, terminating its process group on timeout.
+func (e *cmdz) execute(cfg *config, started func(error)) (rc int, timedOut bool, err error) {
+	cmd := e.cmd
+	terminator := newGroupTerminator(cmd, cfg.termSignal, cfg.termGrace)
+	cmd.Cancel = terminator.terminate
+	var session *ptySession
+	gate, err := wrapProcessSettings(cmd, cfg)
+	if err == nil && e.pty != nil {
+		session, err = openPty(cmd, *e.pty)
+	} else if err == nil && e.asyncInput {
+		err = copyInputAsync(cmd)
+	}
+	if err == nil {
+		err = cmd.Start()
+	}
+	if gate != nil {
+		if err != nil {
+			gate.close()
+		} else if err = gate.apply(cmd.Process.Pid); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+	}
+	if session != nil {
+		if err != nil {
+			session.close()
+		} else {
+			session.start()
+			defer session.wait()
+		}
+	}
+	notifyStarted(started, err)
+	if err != nil {
+		return -1, false, err
+	}
+	var expired atomic.Bool
+	var timer *time.Timer
+	if cfg.timeout > 0 {
+		timer = time.AfterFunc(cfg.timeout, func() {
+			expired.Store(true)
+			_ = terminator.terminate()
+		})
+	}
+	if cfg.ctx != nil {
+		stop := context.AfterFunc(cfg.ctx, func() {
+			_ = terminator.terminate()
+		})
+		defer stop()
+	}
+	err = cmd.Wait()
+	if timer != nil {
+		timer.Stop()
+	}
+	terminator.release()
+	if expired.Load() {
+		return -1, true, nil
+	}
+	if e.ctx.Err() != nil {
+		err = e.ctx.Err()
+	} else if ctxErr := canceled(cfg.ctx); ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitCode(exitErr.ProcessState), false, nil
+		}
+		if errors.Is(err, syscall.EPIPE) && cmd.ProcessState != nil {
+			// Output reader is gone (end of a pipeline exited), keep the process result
+			return exitCode(cmd.ProcessState), false, nil
+		}
+		return -1, false, err
+	}
+	return exitCode(cmd.ProcessState), false, nil
+}
+
+// Execute the in process function once, cancelling its ctx on timeout.
+func (e *cmdz) executeInProcess(cfg *config) (rc int, timedOut bool, err error) {
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+	if cfg.ctx != nil {
+		stop := context.AfterFunc(cfg.ctx, cancel)
+		defer stop()
+	}
+	var expired atomic.Bool
+	if cfg.timeout > 0 {
+		timer := time.AfterFunc(cfg.timeout, func() {
+			expired.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	rc = cfg.inProcess(ctx, e.cmd)
+	if expired.Load() {
+		return -1, true, nil
+	}
+	if err = e.ctx.Err(); err != nil {
+		return -1, false, err
+	}
+	if err = canceled(cfg.ctx); err != nil {
+		return -1, false, err
+	}
+	return rc, false, nil
+}
+
 func (e *cmdz) AsyncRun() *execPromise {
 	p := promiz.New(func(resolve func(int), reject func(error)) {
 		rc, err := e.BlockRun()
@@ -489,6 +1005,11 @@ func (e *cmdz) PipeFail(c *cmdz) *cmdz {
 	return e.Pipe(c)
 }
 
+// PipeStatus return the result code of each stage of the last pipeline run ending with this command.
+func (e *cmdz) PipeStatus() []int {
+	return e.pipeStatus
+}
+
 func (e *cmdz) AddEnv(key, value string) Executer {
 	entry := fmt.Sprintf("%s=%s", key, value)
 	e.environ = append(e.environ, entry)
@@ -5,14 +5,22 @@ import (
 	//"io"
 	"bytes"
 	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 
 	//"log"
 	//"os/exec"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/mxbossard/utilz/errorz"
 	"github.com/mxbossard/utilz/inoutz"
+	"github.com/mxbossard/utilz/printz"
 	"github.com/mxbossard/utilz/promiz"
 
 	"github.com/stretchr/testify/assert"
@@ -48,10 +56,10 @@ func TestString(t *testing.T) {
 	assert.Equal(t, "echo foo", c.String())
 
 	c.AddArgs("bar", "$val")
-	assert.Equal(t, "echo foo bar $val", c.String())
+	assert.Equal(t, "echo foo bar '$val'", c.String())
 
 	c.AddEnv("val", "baz")
-	assert.Equal(t, "echo foo bar $val", c.String())
+	assert.Equal(t, "echo foo bar '$val'", c.String())
 }
 
 func TestBlockRun(t *testing.T) {
@@ -246,8 +254,51 @@ func TestBlockRun_Timeout_ko(t *testing.T) {
 	f.Timeout(10 * time.Millisecond)
 	rc, err := f.BlockRun()
 	require.Error(t, err)
+	assert.True(t, errorz.IsTimeout(err))
 	assert.Equal(t, -1, rc)
 	assert.Equal(t, "", f.StdoutRecord())
+	assert.Equal(t, []int{-1}, f.ResultCodes())
+
+	// Timeout is armed at each run
+	rc, err = f.BlockRun()
+	require.Error(t, err)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Equal(t, -1, rc)
+}
+
+func TestBlockRun_Timeout_KillProcessGroup(t *testing.T) {
+	// Grand children must not survive the timeout and hold the outputs
+	f := Sh("(sleep 0.3 ; echo late) & wait")
+	f.Timeout(20 * time.Millisecond)
+	start := time.Now()
+	rc, err := f.BlockRun()
+	require.Error(t, err)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Equal(t, -1, rc)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+	time.Sleep(400 * time.Millisecond)
+	assert.Equal(t, "", f.StdoutRecord())
+}
+
+func TestBlockRun_Termination(t *testing.T) {
+	// Ignored SIGTERM must be followed by a SIGKILL after grace period
+	f := Sh("trap '' TERM ; sleep 1 ; echo bar")
+	f.Timeout(20 * time.Millisecond)
+	f.Termination(syscall.SIGTERM, 50*time.Millisecond)
+	start := time.Now()
+	rc, err := f.BlockRun()
+	require.Error(t, err)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Equal(t, -1, rc)
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "", f.StdoutRecord())
+
+	// Not a timeout
+	_, err = Cmd("/bin/false").ErrorOnFailure(true).BlockRun()
+	require.Error(t, err)
+	assert.False(t, errorz.IsTimeout(err))
 }
 
 func TestAsyncRun(t *testing.T) {
@@ -348,8 +399,10 @@ func TestPipeFail_OutputString(t *testing.T) {
 	assert.Equal(t, "", o)
 	assert.Equal(t, "", fail.StdoutRecord())
 	assert.Equal(t, "", echo.StdinRecord())
-	assert.Equal(t, "", echo.StdoutRecord())
-	assert.Equal(t, "", p.StdoutRecord())
+	// Stages run concurrently: consumer is run even if pipefail feeder fails
+	assert.Equal(t, "foo", echo.StdoutRecord())
+	assert.Equal(t, "foo", p.StdoutRecord())
+	assert.Equal(t, []int{1, 0}, p.PipeStatus())
 
 	errCmd := Cmd("doNotExists")
 	echo = Cmd("/bin/echo", "-n", "foo")
@@ -563,3 +616,147 @@ func TestOutputProcesser(t *testing.T) {
 	assert.Equal(t, "", wout.String())
 	assert.Equal(t, "PREFIXbaz\n", werr.String())
 }
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := Cmd("sleep", "5").Context(ctx).Termination(syscall.SIGTERM, 100*time.Millisecond)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	rc, err := c.BlockRun()
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, -1, rc)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Already canceled: attempt is not started
+	calls := &hookCalls{}
+	rc, err = Cmd("true").Context(ctx).Hooks(calls.hooks("a")).BlockRun()
+	assert.Equal(t, -1, rc)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, []string{"a failure true: true"}, calls.get())
+
+	// Waiting between retries is interrupted
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	c = Cmd("false").Retries(3, 10000).Context(ctx)
+	start = time.Now()
+	_, err = c.BlockRun()
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []int{1}, c.ResultCodes())
+
+	// Promise reject with ctx error
+	ctx, cancel = context.WithCancel(context.Background())
+	p := Cmd("sleep", "5").Context(ctx).AsyncRun()
+	cancel()
+	_, err = p.Await(context.Background())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRecordLimit(t *testing.T) {
+	c := Sh("seq 1 1000").RecordLimit(8)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "1\n2\n[... 3885 bytes elided ...]000\n", c.StdoutRecord())
+	assert.Empty(t, c.StdoutFile())
+
+	// Limit is inherited from sequences
+	c = Sh("seq 1 1000")
+	_, err = Serial(c).RecordLimit(8).BlockRun()
+	require.NoError(t, err)
+	assert.Contains(t, c.StdoutRecord(), "bytes elided")
+}
+
+func TestSpillRecords(t *testing.T) {
+	c := Sh("seq 1 1000; >&2 echo foo").RecordLimit(8).SpillRecords()
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	require.NotEmpty(t, c.StdoutFile())
+	require.NotEmpty(t, c.StderrFile())
+	defer os.Remove(c.StdoutFile())
+	defer os.Remove(c.StderrFile())
+
+	stdout, err := os.ReadFile(c.StdoutFile())
+	require.NoError(t, err)
+	assert.Len(t, stdout, 3893)
+	assert.True(t, strings.HasSuffix(string(stdout), "999\n1000\n"))
+	stderr, err := os.ReadFile(c.StderrFile())
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n", string(stderr))
+	assert.Equal(t, "foo\n", c.StderrRecord())
+
+	// Spill files of previous run are removed on next run
+	previousStdout, previousStderr := c.StdoutFile(), c.StderrFile()
+	_, err = c.BlockRun()
+	require.NoError(t, err)
+	defer os.Remove(c.StdoutFile())
+	defer os.Remove(c.StderrFile())
+	assert.NoFileExists(t, previousStdout)
+	assert.NoFileExists(t, previousStderr)
+	assert.FileExists(t, c.StdoutFile())
+}
+
+func TestInProcess(t *testing.T) {
+	echo := func(ctx context.Context, c *Vcmd) int {
+		_, _ = io.WriteString(c.Stdout, strings.Join(c.Args[1:], " "))
+		return len(c.Args) - 1
+	}
+	c := Cmd("not_existing_binary", "foo", "bar").InProcess(echo)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 2, rc)
+	assert.Equal(t, "foo bar", c.StdoutRecord())
+
+	// Propagated to sequence children
+	c1 := Cmd("not_existing_binary", "baz")
+	rc, err = Serial(c1).InProcess(echo).BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, "baz", c1.StdoutRecord())
+}
+
+func TestInProcess_Timeout(t *testing.T) {
+	block := func(ctx context.Context, c *Vcmd) int {
+		<-ctx.Done()
+		return 0
+	}
+	start := time.Now()
+	_, err := Cmd("block").InProcess(block).Timeout(50 * time.Millisecond).BlockRun()
+	assert.True(t, errorz.IsTimeout(err), "expected a timeout, got: %v", err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = Cmd("block").InProcess(block).Context(ctx).BlockRun()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTermination_Release(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	setupProcessGroup(cmd)
+	require.NoError(t, cmd.Start())
+	terminator := newGroupTerminator(cmd, syscall.SIGTERM, 50*time.Millisecond)
+	require.NoError(t, terminator.terminate())
+	_ = cmd.Wait()
+	terminator.release()
+	// Group exited within grace period: pending SIGKILL is cancelled
+	assert.False(t, terminator.kill.Stop())
+	// Reaped leader is never signaled again
+	assert.NoError(t, terminator.terminate())
+}
+
+func TestInvalid(t *testing.T) {
+	invalid := errors.New("bad option")
+	path := filepath.Join(t.TempDir(), "file")
+	e := Invalid(invalid, "touch", path)
+	assert.Equal(t, "touch "+path, e.String())
+	_, err := e.BlockRun()
+	assert.ErrorIs(t, err, invalid)
+	assert.NoFileExists(t, path)
+
+	_, err = Serial(Cmd("true"), Invalid(invalid, "true")).BlockRun()
+	assert.ErrorIs(t, err, invalid)
+	_, err = Invalid(invalid, "true").DryRun(printz.New(printz.NewDiscardingOutputs())).BlockRun()
+	assert.ErrorIs(t, err, invalid)
+}
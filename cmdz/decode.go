@@ -0,0 +1,144 @@
+package cmdz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/mxbossard/utilz/serializ"
+)
+
+// Decoder build the result of a Formatter from an execution result code and outputs.
+// Decoders fail on a non zero result code, unless wrapped with EmptyOnRc.
+type Decoder[O any] func(rc int, stdout, stderr []byte) (O, error)
+
+// Wrap a decoding func of stdout into a Decoder failing on non zero result code.
+// A blank stdout decodes into the zero value of O.
+func stdoutDecoder[O any](decode func(stdout []byte, out *O) error) Decoder[O] {
+	return func(rc int, stdout, stderr []byte) (out O, err error) {
+		if rc != 0 {
+			return out, failure{Rc: rc}
+		}
+		if len(bytes.TrimSpace(stdout)) == 0 {
+			return
+		}
+		err = decode(stdout, &out)
+		return
+	}
+}
+
+// JSON decode stdout as a JSON document.
+func JSON[O any]() Decoder[O] {
+	return stdoutDecoder(func(stdout []byte, out *O) error {
+		err := json.Unmarshal(stdout, out)
+		if err != nil {
+			return fmt.Errorf("Unable to decode json output ! Caused by: %w", err)
+		}
+		return nil
+	})
+}
+
+// YAML decode stdout as a YAML document. Fields are mapped with json tags.
+func YAML[O any]() Decoder[O] {
+	return stdoutDecoder(func(stdout []byte, out *O) error {
+		return serializ.UnmarshalYaml(stdout, out)
+	})
+}
+
+// NDJSON decode stdout as a stream of JSON documents, usually one per line.
+func NDJSON[O any]() Decoder[[]O] {
+	return stdoutDecoder(func(stdout []byte, out *[]O) error {
+		decoder := json.NewDecoder(bytes.NewReader(stdout))
+		for {
+			var item O
+			err := decoder.Decode(&item)
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("Unable to decode json stream item #%d ! Caused by: %w", len(*out)+1, err)
+			}
+			*out = append(*out, item)
+		}
+	})
+}
+
+// Lines split stdout into lines, without their line terminator.
+func Lines() Decoder[[]string] {
+	return stdoutDecoder(func(stdout []byte, out *[]string) error {
+		scanner := bufio.NewScanner(bytes.NewReader(stdout))
+		scanner.Buffer(nil, len(stdout)+1)
+		for scanner.Scan() {
+			*out = append(*out, strings.TrimSuffix(scanner.Text(), "\r"))
+		}
+		return scanner.Err()
+	})
+}
+
+// KeyValues decode stdout env-style: one key=value per line. Blank lines and # comments are ignored,
+// quoted values are unquoted like a shell does.
+func KeyValues() Decoder[map[string]string] {
+	return stdoutDecoder(func(stdout []byte, out *map[string]string) error {
+		*out = make(map[string]string)
+		for n, line := range strings.Split(string(stdout), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, ok := strings.Cut(line, "=")
+			key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+			if !ok || key == "" {
+				return fmt.Errorf("Unable to decode key=value line #%d: %q !", n+1, line)
+			}
+			value = strings.TrimSpace(value)
+			if strings.HasPrefix(value, "'") || strings.HasPrefix(value, "\"") {
+				words, err := Split(value)
+				if err != nil || len(words) != 1 {
+					return fmt.Errorf("Unable to decode value of key %s line #%d: %q !", key, n+1, value)
+				}
+				value = words[0]
+			}
+			(*out)[key] = value
+		}
+		return nil
+	})
+}
+
+// CSV decode stdout as comma separated records.
+func CSV() Decoder[[][]string] {
+	return Table(',')
+}
+
+// TSV decode stdout as tab separated records.
+func TSV() Decoder[[][]string] {
+	return Table('\t')
+}
+
+// Table decode stdout as records separated by comma. Records may have a variable number of fields.
+func Table(comma rune) Decoder[[][]string] {
+	return stdoutDecoder(func(stdout []byte, out *[][]string) (err error) {
+		reader := csv.NewReader(bytes.NewReader(stdout))
+		reader.Comma = comma
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = comma == '\t'
+		*out, err = reader.ReadAll()
+		if err != nil {
+			return fmt.Errorf("Unable to decode table output ! Caused by: %w", err)
+		}
+		return nil
+	})
+}
+
+// EmptyOnRc decode result codes listed in rcs as an empty result instead of a failure, like grep rc 1 meaning no match.
+func EmptyOnRc[O any](decoder Decoder[O], rcs ...int) Decoder[O] {
+	return func(rc int, stdout, stderr []byte) (out O, err error) {
+		if rc != 0 && slices.Contains(rcs, rc) {
+			return
+		}
+		return decoder(rc, stdout, stderr)
+	}
+}
@@ -0,0 +1,103 @@
+package cmdz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodedItem struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestJSON(t *testing.T) {
+	items, err := FormattedCmd(JSON[[]decodedItem](), "echo", `[{"name": "foo", "count": 2}, {"name": "bar"}]`).Format()
+	require.NoError(t, err)
+	assert.Equal(t, []decodedItem{{Name: "foo", Count: 2}, {Name: "bar"}}, items)
+
+	item, err := FormattedCmd(JSON[decodedItem](), "echo", `{"name": "foo"}`).Format()
+	require.NoError(t, err)
+	assert.Equal(t, decodedItem{Name: "foo"}, item)
+
+	items, err = FormattedCmd(JSON[[]decodedItem](), "echo", "").Format()
+	require.NoError(t, err)
+	assert.Nil(t, items)
+
+	_, err = FormattedCmd(JSON[[]decodedItem](), "echo", "not json").Format()
+	assert.ErrorContains(t, err, "Unable to decode json output")
+
+	_, err = FormattedCmd(JSON[[]decodedItem](), "sh", "-c", "echo '[]'; echo oops >&2; exit 3").Format()
+	require.Error(t, err)
+	var fail failure
+	require.ErrorAs(t, err, &fail)
+	assert.Equal(t, 3, fail.Rc)
+	assert.Contains(t, err.Error(), "sh -c")
+}
+
+func TestYAML(t *testing.T) {
+	items, err := FormattedCmd(YAML[[]decodedItem](), "printf", "- name: foo\n  count: 2\n- name: bar\n").Format()
+	require.NoError(t, err)
+	assert.Equal(t, []decodedItem{{Name: "foo", Count: 2}, {Name: "bar"}}, items)
+
+	_, err = FormattedCmd(YAML[[]decodedItem](), "printf", "- name: [foo\n").Format()
+	assert.Error(t, err)
+}
+
+func TestNDJSON(t *testing.T) {
+	items, err := FormattedCmd(NDJSON[decodedItem](), "printf", `{"name": "foo", "count": 2}\n{"name": "bar"}\n`).Format()
+	require.NoError(t, err)
+	assert.Equal(t, []decodedItem{{Name: "foo", Count: 2}, {Name: "bar"}}, items)
+
+	_, err = FormattedCmd(NDJSON[decodedItem](), "printf", `{"name": "foo"}\n{"name":\n`).Format()
+	assert.ErrorContains(t, err, "item #2")
+}
+
+func TestLines(t *testing.T) {
+	lines, err := FormattedCmd(Lines(), "printf", "foo\n\nbar baz\r\nqux").Format()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo", "", "bar baz", "qux"}, lines)
+
+	lines, err = FormattedCmd(Lines(), "true").Format()
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+}
+
+func TestKeyValues(t *testing.T) {
+	kv, err := FormattedCmd(KeyValues(), "printf", "# comment\nFOO=bar\n\nexport BAZ=\"a b\"\nQUX='it'\\\\''s'\nEMPTY=\nEQ=a=b\n").Format()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"FOO": "bar", "BAZ": "a b", "QUX": "it's", "EMPTY": "", "EQ": "a=b"}, kv)
+
+	_, err = FormattedCmd(KeyValues(), "echo", "no value").Format()
+	assert.ErrorContains(t, err, "line #1")
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := FormattedCmd(CSV(), "printf", "name,count\nfoo,2\n\"b,ar\",\n").Format()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "count"}, {"foo", "2"}, {"b,ar", ""}}, rows)
+
+	rows, err = FormattedCmd(TSV(), "printf", "name\tcount\nfoo \"x\"\t2\n").Format()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"name", "count"}, {`foo "x"`, "2"}}, rows)
+
+	rows, err = FormattedCmd(Table(';'), "echo", "a;b;c").Format()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "b", "c"}}, rows)
+}
+
+func TestEmptyOnRc(t *testing.T) {
+	lines, err := FormattedCmd(EmptyOnRc(Lines(), 1), "grep", "foo", "/dev/null").Format()
+	require.NoError(t, err)
+	assert.Empty(t, lines)
+
+	lines, err = FormattedCmd(EmptyOnRc(Lines(), 1), "sh", "-c", "echo foo | grep foo").Format()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, lines)
+
+	_, err = FormattedCmd(EmptyOnRc(Lines(), 1), "grep", "foo", "/does/not/exist").Format()
+	var fail failure
+	require.ErrorAs(t, err, &fail)
+	assert.Equal(t, 2, fail.Rc)
+}
@@ -0,0 +1,113 @@
+package cmdz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mxbossard/utilz/printz"
+)
+
+const dryRunIndent = "  "
+
+func printDryRun(cfg *config, line string) {
+	cfg.dryRun.Outf("%s%s\n", strings.Repeat(dryRunIndent, cfg.dryRunDepth), line)
+	_ = cfg.dryRun.Flush()
+}
+
+// In dry-run mode print the sequence header and return the config its children inherit, indented one level deeper.
+func dryRunSeq(cfg *config, header string) *config {
+	if cfg.dryRun == nil {
+		return cfg
+	}
+	printDryRun(cfg, header+":")
+	children := *cfg
+	children.dryRunDepth++
+	return &children
+}
+
+func dryRunResult(cfg *config) int {
+	if cfg.dryRunRc != nil {
+		return *cfg.dryRunRc
+	}
+	return 0
+}
+
+// Describe what would be executed: env additions, working dir, timeout, retries and simulated result code.
+func dryRunLine(command string, cfg *config, environ []string, rc int) string {
+	var details []string
+	if env := redactAll(envDelta(environ), cfg.secrets); len(env) > 0 {
+		details = append(details, "env: "+strings.Join(env, " "))
+	}
+	if cfg.dir != "" {
+		details = append(details, "dir: "+cfg.dir)
+	}
+	if cfg.timeout > 0 {
+		details = append(details, fmt.Sprintf("timeout: %s", cfg.timeout))
+	}
+	if cfg.retries > 0 {
+		details = append(details, fmt.Sprintf("retries: %d", cfg.retries))
+	}
+	if rc != 0 {
+		details = append(details, fmt.Sprintf("rc: %d", rc))
+	}
+	if len(details) > 0 {
+		command += " (" + strings.Join(details, ", ") + ")"
+	}
+	return command
+}
+
+// Simulate the execution of a prepared command.
+func (e *cmdz) dryRun(cfg *config) (rc int, err error) {
+	if e.invalid != nil {
+		return -1, e.invalid
+	}
+	rc = dryRunResult(cfg)
+	printDryRun(cfg, dryRunLine(e.String(), cfg, e.environ, rc))
+	e.startTimes = append(e.startTimes, time.Now())
+	e.durations = append(e.durations, 0)
+	e.exitCodes = append(e.exitCodes, rc)
+	if e.errorOnFailure && rc > 0 {
+		err = failure{rc, e}
+		rc = -1
+	}
+	return
+}
+
+// Simulate the execution of the pipeline ending with e. Stages without simulated result code succeed.
+func (e *cmdz) dryRunPipeline(cfg *config) (rc int, err error) {
+	var stages []*cmdz
+	for s := e; s != nil; s = s.feeder {
+		stages = append([]*cmdz{s}, stages...)
+	}
+	commands := make([]string, len(stages))
+	rcs := make([]int, len(stages))
+	for i, s := range stages {
+		s.reset()
+		commands[i] = s.String()
+		stageCfg := cfg
+		if s != e {
+			stageCfg = &s.config
+		}
+		rcs[i] = dryRunResult(stageCfg)
+		s.startTimes = append(s.startTimes, time.Now())
+		s.durations = append(s.durations, 0)
+		s.exitCodes = append(s.exitCodes, rcs[i])
+	}
+	e.pipeStatus = rcs
+	rc = pipelineResult(stages, rcs)
+	printDryRun(cfg, dryRunLine(strings.Join(commands, " | "), cfg, e.environ, rc))
+	if e.errorOnFailure && rc > 0 {
+		err = failure{rc, e}
+		rc = -1
+	}
+	return
+}
+
+// Default dry-run printer if none is configured.
+func dryRunPrinter(printer printz.Printer) printz.Printer {
+	if printer == nil {
+		return printz.NewStandard()
+	}
+	return printer
+}
@@ -0,0 +1,118 @@
+package cmdz
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/printz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dryRunPrinterTo(out *bytes.Buffer) printz.Printer {
+	return printz.New(printz.NewOutputs(out, out))
+}
+
+func TestDryRun(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	out := &bytes.Buffer{}
+	c := Cmd("touch", marker).AddEnv("FOO", "bar").Timeout(time.Second).Retries(2, 0).DryRun(dryRunPrinterTo(out))
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []int{0}, c.ResultCodes())
+	assert.NoFileExists(t, marker)
+	assert.Equal(t, "touch "+marker+" (env: FOO=bar, timeout: 1s, retries: 2)\n", out.String())
+
+	out.Reset()
+	c = Cmd("true").DryRun(dryRunPrinterTo(out)).DryRunRc(3)
+	rc, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+	assert.Equal(t, "true (rc: 3)\n", out.String())
+
+	out.Reset()
+	_, err = c.ErrorOnFailure(true).BlockRun()
+	var fail failure
+	require.ErrorAs(t, err, &fail)
+	assert.Equal(t, 3, fail.Rc)
+}
+
+func TestDryRun_Tree(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	out := &bytes.Buffer{}
+	s := Serial(
+		Cmd("echo", "start").Dir("/tmp"),
+		Or(
+			Cmd("test", "-f", marker).DryRunRc(1),
+			And(Cmd("touch", marker), Cmd("echo", "a b")),
+		),
+		Parallel(Cmd("echo", "p1"), Cmd("echo", "p2").AddEnv("SECRET", "s3cr3t").Secrets("s3cr3t")),
+		Cmd("echo", "foo").Pipe(Cmd("tr", "o", "0")),
+	).DryRun(dryRunPrinterTo(out))
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.NoFileExists(t, marker)
+	assert.Equal(t, `serial:
+  echo start (dir: /tmp)
+  or:
+    test -f `+marker+` (rc: 1)
+    and:
+      touch `+marker+`
+      echo 'a b'
+  parallel:
+    echo p1
+    echo p2 (env: SECRET=******)
+  echo foo | tr o 0
+`, out.String())
+}
+
+func TestDryRun_ExploreBranches(t *testing.T) {
+	out := &bytes.Buffer{}
+	a := And(Cmd("check").DryRunRc(1), Cmd("deploy")).DryRun(dryRunPrinterTo(out))
+	rc, err := a.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, "and:\n  check (rc: 1)\n", out.String())
+
+	out.Reset()
+	o := Or(Cmd("check"), Cmd("repair")).DryRun(dryRunPrinterTo(out)).DryRunRc(2)
+	rc, err = o.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 2, rc)
+	assert.Equal(t, "or:\n  check (rc: 2)\n  repair (rc: 2)\n", out.String())
+
+	out.Reset()
+	c := Cmd("cat", "missing")
+	c.DryRunRc(1)
+	p := c.PipeFail(Cmd("wc", "-l"))
+	p.DryRun(dryRunPrinterTo(out))
+	rc, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []int{1, 0}, p.PipeStatus())
+	assert.Equal(t, "cat missing | wc -l (rc: 1)\n", out.String())
+}
+
+func TestDryRun_Graph(t *testing.T) {
+	out := &bytes.Buffer{}
+	g := Graph().
+		Node("build", Cmd("make")).
+		Node("test", Cmd("make", "test"), "build").
+		Node("lint", Cmd("make", "lint"), "build").
+		Fork(4).
+		DryRun(dryRunPrinterTo(out))
+	rc, err := g.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "graph:\n  make\n  make test\n  make lint\n", out.String())
+}
+
+func TestDryRun_DefaultPrinter(t *testing.T) {
+	assert.NotNil(t, dryRunPrinter(nil))
+	p := printz.NewStandard()
+	assert.Same(t, p, dryRunPrinter(p))
+}
@@ -0,0 +1,87 @@
+package cmdz
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const redactedSecret = "******"
+
+type envMode int
+
+const (
+	envUnset envMode = iota
+	envInheritAll
+	envInheritAllowed
+	envClean
+)
+
+// Build the process environment: inherited entries according to the env mode followed by added entries.
+// Without env mode, like exec.Cmd, all environment is inherited unless some entries were added.
+func buildEnviron(cfg *config, added []string) []string {
+	if cfg.envMode == envUnset && len(added) == 0 {
+		return nil
+	}
+	// A nil environment would inherit all
+	environ := []string{}
+	switch cfg.envMode {
+	case envInheritAll:
+		environ = append(environ, os.Environ()...)
+	case envInheritAllowed:
+		for _, entry := range os.Environ() {
+			key, _, _ := strings.Cut(entry, "=")
+			if envAllowed(key, cfg.envAllowed) {
+				environ = append(environ, entry)
+			}
+		}
+	}
+	return append(environ, added...)
+}
+
+func envAllowed(key string, allowed []string) bool {
+	for _, a := range allowed {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok && strings.HasPrefix(key, prefix) || a == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace all occurrences of secrets in s.
+func redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedSecret)
+		}
+	}
+	return s
+}
+
+func redactAll(values []string, secrets []string) []string {
+	if len(secrets) == 0 || values == nil {
+		return values
+	}
+	redacted := make([]string, len(values))
+	for i, v := range values {
+		redacted[i] = redact(v, secrets)
+	}
+	return redacted
+}
+
+// Secrets of the command including the ones inherited from sequences.
+func (e *cmdz) secrets() []string {
+	if e.fallbackConfig == nil {
+		return e.config.secrets
+	}
+	return mergeConfigs(&e.config, e.fallbackConfig).secrets
+}
+
+// LogValue implements slog.LogValuer, so logging a command never leaks its secrets.
+func (e *cmdz) LogValue() slog.Value {
+	secrets := e.secrets()
+	return slog.GroupValue(
+		slog.String("cmd", e.String()),
+		slog.Any("env", redactAll(envDelta(e.environ), secrets)),
+	)
+}
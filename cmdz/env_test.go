@@ -0,0 +1,116 @@
+package cmdz
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnv_Modes(t *testing.T) {
+	t.Setenv("CMDZ_TEST_FOO", "foo")
+	t.Setenv("CMDZ_TEST_BAR", "bar")
+	t.Setenv("CMDZ_OTHER", "other")
+
+	printEnv := func() *cmdz {
+		return Cmd("sh", "-c", `echo "$CMDZ_TEST_FOO,$CMDZ_TEST_BAR,$CMDZ_OTHER,$ADDED"`)
+	}
+
+	// Default inherit all without added entries
+	o, err := Outputted(printEnv()).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo,bar,other,\n", o)
+
+	// Default pass only added entries
+	o, err = Outputted(printEnv().AddEnv("ADDED", "added")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, ",,,added\n", o)
+
+	o, err = Outputted(printEnv().InheritEnv().AddEnv("ADDED", "added")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo,bar,other,added\n", o)
+
+	o, err = Outputted(printEnv().InheritEnv()).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo,bar,other,\n", o)
+
+	o, err = Outputted(printEnv().InheritEnv("CMDZ_TEST_*", "CMDZ_OTHE").AddEnv("ADDED", "added")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo,bar,,added\n", o)
+
+	o, err = Outputted(printEnv().InheritEnv("CMDZ_OTHER")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, ",,other,\n", o)
+
+	o, err = Outputted(printEnv().CleanEnv().AddEnv("ADDED", "added")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, ",,,added\n", o)
+
+	// Added entries override inherited ones
+	o, err = Outputted(printEnv().InheritEnv().AddEnv("CMDZ_OTHER", "overridden")).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo,bar,overridden,\n", o)
+}
+
+func TestEnv_ModeInheritedFromSequence(t *testing.T) {
+	t.Setenv("CMDZ_TEST_FOO", "foo")
+	c1 := Cmd("sh", "-c", `echo "1$CMDZ_TEST_FOO"`)
+	c2 := Cmd("sh", "-c", `echo "2$CMDZ_TEST_FOO"`).InheritEnv()
+	s := Serial(c1, c2).CleanEnv()
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "1\n2foo\n", s.StdoutRecord())
+}
+
+func TestEnv_CleanEnvIsEmpty(t *testing.T) {
+	require.NotEmpty(t, os.Environ())
+	o, err := Outputted(Cmd("env").CleanEnv()).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "", o)
+}
+
+func TestSecrets(t *testing.T) {
+	c := Cmd("sh", "-c", "echo token=$TOKEN; echo s3cr3t >&2; exit 1", "--password=s3cr3t").
+		AddEnv("TOKEN", "s3cr3t").
+		Secrets("s3cr3t")
+	assert.Equal(t, "sh -c 'echo token=$TOKEN; echo ****** >&2; exit 1' '--password=******'", c.String())
+
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, "token=******\n", c.StdoutRecord())
+	assert.Equal(t, "******\n", c.StderrRecord())
+	assert.NotContains(t, c.ReportError(), "s3cr3t")
+
+	r := Report(c)
+	assert.Equal(t, []string{"TOKEN=******"}, r.Env)
+	assert.Equal(t, "--password=******", r.Argv[3])
+	b, err := r.JSON()
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "s3cr3t")
+
+	_, err = c.ErrorOnFailure(true).BlockRun()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("executing", "exec", c)
+	assert.Contains(t, logs.String(), "TOKEN=******")
+	assert.NotContains(t, logs.String(), "s3cr3t")
+}
+
+func TestSecrets_FromSequence(t *testing.T) {
+	c := Cmd("echo", "s3cr3t")
+	s := Serial(c, Cmd("echo", "other")).Secrets("s3cr3t")
+	assert.Equal(t, "echo '******'\necho other", s.String())
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "******\n", c.StdoutRecord())
+	assert.Equal(t, "******\nother\n", s.StdoutRecord())
+}
@@ -0,0 +1,216 @@
+package cmdz
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/mxbossard/utilz/errorz"
+	"github.com/mxbossard/utilz/ztring"
+)
+
+// Output of a process watched by an interaction. Written bytes are kept until an expectation consumes them.
+type expectStream struct {
+	sync.Mutex
+	name    string
+	nested  io.Writer
+	buffer  bytes.Buffer
+	changed chan struct{}
+	// Pass writes through without buffering them
+	detached bool
+}
+
+func newExpectStream(name string, nested io.Writer) *expectStream {
+	return &expectStream{name: name, nested: nested, changed: make(chan struct{})}
+}
+
+func (s *expectStream) Write(p []byte) (int, error) {
+	s.Lock()
+	if !s.detached {
+		s.buffer.Write(p)
+	}
+	// Wake up waiting expectations
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.Unlock()
+	if s.nested != nil {
+		return s.nested.Write(p)
+	}
+	return len(p), nil
+}
+
+// Consume the buffer up to the end of the first match of re. Return nil groups and a channel closed on next write if no match.
+func (s *expectStream) match(re *regexp.Regexp) ([]string, <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+	loc := re.FindSubmatchIndex(s.buffer.Bytes())
+	if loc == nil {
+		return nil, s.changed
+	}
+	groups := make([]string, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] >= 0 {
+			groups[i] = string(s.buffer.Bytes()[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	s.buffer.Next(loc[1])
+	return groups, nil
+}
+
+// Drop all not consumed output and buffer next writes.
+func (s *expectStream) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.buffer.Reset()
+	s.detached = false
+}
+
+// Drop all not consumed output and stop buffering once nothing is expected anymore.
+func (s *expectStream) detach() {
+	s.Lock()
+	defer s.Unlock()
+	s.buffer.Reset()
+	s.detached = true
+}
+
+func (s *expectStream) unmatched() string {
+	s.Lock()
+	defer s.Unlock()
+	return ztring.ElideRatio(s.buffer.String(), 128, 0.2)
+}
+
+// interaction drive a running process like expect: wait for patterns in its outputs and send it input.
+type interaction struct {
+	exec   *cmdz
+	name   string
+	input  *io.PipeWriter
+	stdout *expectStream
+	stderr *expectStream
+
+	started bool
+	done    chan struct{}
+	rc      int
+	err     error
+}
+
+// Interact build an expect-like dialogue with e. Outputs are still written to e configured outputs and recorded.
+func Interact(e *cmdz) *interaction {
+	return &interaction{exec: e, name: e.String(), done: make(chan struct{})}
+}
+
+// Start the process in background.
+func (i *interaction) Start() error {
+	if i.started {
+		return fmt.Errorf("Interaction already started with: [%s] !", i.name)
+	}
+	i.started = true
+	reader, writer := io.Pipe()
+	i.input = writer
+	stdin, stdout, stderr := i.exec.Stdin(), i.exec.Stdout(), i.exec.Stderr()
+	i.stdout = newExpectStream("stdout", stdout)
+	i.stderr = newExpectStream("stderr", stderr)
+	i.exec.SetInput(reader)
+	i.exec.asyncInput = true
+	i.exec.SetOutputs(i.stdout, i.stderr)
+	go func() {
+		defer close(i.done)
+		i.rc, i.err = i.exec.BlockRun()
+		_ = reader.Close()
+		i.exec.asyncInput = false
+		i.exec.SetInput(stdin)
+		i.exec.SetOutputs(stdout, stderr)
+	}()
+	return nil
+}
+
+// Expect wait until pattern match the process stdout. Return the match followed by its submatches.
+func (i *interaction) Expect(pattern string, timeout time.Duration) ([]string, error) {
+	return i.expect(i.stdout, pattern, timeout)
+}
+
+// ExpectErr wait until pattern match the process stderr. Return the match followed by its submatches.
+func (i *interaction) ExpectErr(pattern string, timeout time.Duration) ([]string, error) {
+	return i.expect(i.stderr, pattern, timeout)
+}
+
+func (i *interaction) expect(stream *expectStream, pattern string, timeout time.Duration) ([]string, error) {
+	if !i.started {
+		return nil, fmt.Errorf("Interaction not started with: [%s] !", i.name)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		groups, changed := stream.match(re)
+		if groups != nil {
+			return groups, nil
+		}
+		select {
+		case <-changed:
+		case <-i.done:
+			// Outputs are flushed once the process exited, try a last match
+			if groups, _ := stream.match(re); groups != nil {
+				return groups, nil
+			}
+			return nil, fmt.Errorf("Process exited with ResultCode: %d before /%s/ matched on %s: %q !", i.rc, pattern, stream.name, stream.unmatched())
+		case <-timer.C:
+			return nil, errorz.Timeoutf(timeout, "expecting /%s/ on %s of [%s] ! Unmatched output: %q", pattern, stream.name, i.name, stream.unmatched())
+		}
+	}
+}
+
+// Send write input to the process stdin.
+func (i *interaction) Send(input string) error {
+	if !i.started {
+		return fmt.Errorf("Interaction not started with: [%s] !", i.name)
+	}
+	_, err := io.WriteString(i.input, input)
+	if err != nil {
+		return fmt.Errorf("Unable to send input to [%s] ! Caused by: %w", i.name, err)
+	}
+	return nil
+}
+
+// SendLine write input followed by a new line to the process stdin.
+func (i *interaction) SendLine(line string) error {
+	return i.Send(line + "\n")
+}
+
+// CloseInput send EOF to the process stdin.
+func (i *interaction) CloseInput() error {
+	if !i.started {
+		return nil
+	}
+	return i.input.Close()
+}
+
+// Wait close the process stdin and wait for the process to exit.
+func (i *interaction) Wait(timeout time.Duration) (int, error) {
+	if err := i.CloseInput(); err != nil {
+		return -1, err
+	}
+	select {
+	case <-i.done:
+		return i.rc, i.err
+	case <-time.After(timeout):
+		return -1, errorz.Timeoutf(timeout, "waiting exit of [%s]", i.name)
+	}
+}
+
+// ExpectExit wait for the process to exit with result code rc.
+func (i *interaction) ExpectExit(rc int, timeout time.Duration) error {
+	actual, err := i.Wait(timeout)
+	if err != nil {
+		return err
+	}
+	if actual != rc {
+		return fmt.Errorf("Expected ResultCode: %d but got: %d executing: [%s] !", rc, actual, i.name)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmdz
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/errorz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInteract(t *testing.T) {
+	stdout := &strings.Builder{}
+	e := Sh(`printf "Name: "; read -r name; echo "Hello $name"; >&2 printf "Age? "; read -r age; echo "age=$age"; exit 3`)
+	e.SetStdout(stdout)
+	i := Interact(e)
+	require.NoError(t, i.Start())
+
+	_, err := i.Expect(`Name: $`, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, i.SendLine("Bob"))
+	groups, err := i.Expect(`Hello (\w+)`, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Hello Bob", "Bob"}, groups)
+
+	_, err = i.ExpectErr(`Age\? `, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, i.SendLine("42"))
+	_, err = i.Expect(`age=42`, time.Second)
+	require.NoError(t, err)
+
+	assert.NoError(t, i.ExpectExit(3, time.Second))
+	assert.Equal(t, "Name: Hello Bob\nage=42\n", stdout.String())
+	assert.Equal(t, "Name: Hello Bob\nage=42\n", e.StdoutRecord())
+	assert.Equal(t, "Bob\n42\n", e.StdinRecord())
+	assert.Equal(t, stdout, e.Stdout())
+}
+
+func TestInteract_Failures(t *testing.T) {
+	i := Interact(Sh(`echo foo; read -r a`))
+	_, err := i.Expect(`foo`, time.Second)
+	assert.Error(t, err)
+	require.NoError(t, i.Start())
+	assert.Error(t, i.Start())
+
+	_, err = i.Expect(`bar`, 50*time.Millisecond)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Contains(t, err.Error(), `"foo\n"`)
+	// Unmatched output is not consumed
+	_, err = i.Expect(`fo+`, time.Second)
+	assert.NoError(t, err)
+
+	assert.Error(t, i.ExpectExit(2, time.Second))
+
+	i = Interact(Sh(`echo foo`))
+	require.NoError(t, i.Start())
+	_, err = i.Expect(`bar`, time.Second)
+	assert.ErrorContains(t, err, "Process exited")
+
+	i = Interact(Cmd("sleep", "1"))
+	require.NoError(t, i.Start())
+	_, err = i.Wait(50 * time.Millisecond)
+	assert.True(t, errorz.IsTimeout(err))
+}
+
+func TestInteract_Pty(t *testing.T) {
+	e := Sh(`stty -echo; [ -t 0 ] && printf "Password: "; read -r pass; echo; echo "got $pass"`).Pty(PtyOptions{Echo: true})
+	i := Interact(e)
+	require.NoError(t, i.Start())
+	_, err := i.Expect(`Password: `, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, i.SendLine("secret"))
+	groups, err := i.Expect(`got (\w+)`, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", groups[1])
+	assert.NoError(t, i.ExpectExit(0, time.Second))
+	assert.Equal(t, "Password: \r\ngot secret\r\n", e.StdoutRecord())
+}
@@ -3,6 +3,8 @@ package cmdz
 import (
 	"context"
 	"strings"
+
+	"github.com/mxbossard/utilz/cachz"
 )
 
 // ----- Commands -----
@@ -31,6 +33,13 @@ func CmdCtx(ctx context.Context, binaryAndArgs ...string) *cmdz {
 	return e
 }
 
+// Invalid build a command which is never executed: its runs return err. It let builders report invalid options on run.
+func Invalid(err error, binaryAndArgs ...string) *cmdz {
+	e := Cmd(binaryAndArgs...)
+	e.invalid = err
+	return e
+}
+
 func Sh(cmd ...string) *cmdz {
 	return Cmd("sh", "-c", strings.Join(cmd, " "))
 }
@@ -50,7 +59,7 @@ func Parallel(execs ...Executer) *parallelSeq {
 
 func And(execs ...Executer) *andSeq {
 	s := &andSeq{serialSeq: *Serial(execs...)}
-	s.Add(execs...)
+	s.failFast = true
 	return s
 }
 
@@ -60,6 +69,11 @@ func Or(execs ...Executer) *orSeq {
 	return s
 }
 
+// Graph build an empty dependency graph. Add nodes with Node().
+func Graph() *graphSeq {
+	return &graphSeq{seq: &seq{config: config{}}}
+}
+
 // ----- Outputters -----
 func Outputted(e Executer) Outputer {
 	return &basicOutput{Executer: e}
@@ -79,6 +93,14 @@ func FormattedCmd[O any](f func(int, []byte, []byte) (O, error), binaryAndArgs .
 	return Formatted(c, f)
 }
 
+// ----- Cachers -----
+// Cached replay the result of e from cache when its argv, selected env and inputs did not change.
+// Only successful executions with complete outputs are cached. Stdin does not take part in the cache key.
+// Outputs are cached unredacted, so the cache must be as private as the secrets of e.
+func Cached(e Executer, cache cachz.Cache[string]) *cachedExec {
+	return &cachedExec{Executer: e, cache: cache}
+}
+
 // ----- Pipers -----
 func Pipable(i Executer) Piper {
 	return &basicPipe{feeder: i}
@@ -0,0 +1,119 @@
+package cmdz
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RecordedExecution is one attempt of a command captured in a fixture.
+type RecordedExecution struct {
+	Argv     []string      `json:"argv" yaml:"argv"`
+	Env      []string      `json:"env,omitempty" yaml:"env,omitempty"`
+	Stdin    string        `json:"stdin,omitempty" yaml:"stdin,omitempty"`
+	Stdout   string        `json:"stdout" yaml:"stdout"`
+	Stderr   string        `json:"stderr" yaml:"stderr"`
+	Rc       int           `json:"rc" yaml:"rc"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+}
+
+// Recording capture real executions of commands it is attached to with Record(),
+// to save them in a fixture file which can be replayed later with ReplayMock().
+type Recording struct {
+	mu         sync.Mutex
+	path       string
+	Executions []RecordedExecution `json:"executions" yaml:"executions"`
+}
+
+func NewRecording(path string) *Recording {
+	return &Recording{path: path}
+}
+
+func LoadRecording(path string) (*Recording, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read fixture file %s ! Caused by: %w", path, err)
+	}
+	r := NewRecording(path)
+	if isYamlFile(path) {
+		err = yaml.Unmarshal(content, r)
+	} else {
+		err = json.Unmarshal(content, r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal fixture file %s ! Caused by: %w", path, err)
+	}
+	return r, nil
+}
+
+func isYamlFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Record an execution with its secrets redacted, so fixtures can be committed.
+func (r *Recording) record(cmd *exec.Cmd, stdin, stdout, stderr []byte, rc int, duration time.Duration, secrets []string) {
+	recorded := RecordedExecution{
+		Argv:     redactAll(append([]string{}, cmd.Args...), secrets),
+		Env:      redactAll(envDelta(cmd.Env), secrets),
+		Stdin:    redact(string(stdin), secrets),
+		Stdout:   redact(string(stdout), secrets),
+		Stderr:   redact(string(stderr), secrets),
+		Rc:       rc,
+		Duration: duration,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Executions = append(r.Executions, recorded)
+}
+
+// Save recorded executions in fixture file. Format is YAML if file extension is .yaml or .yml, JSON otherwise.
+func (r *Recording) Save() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var content []byte
+	if isYamlFile(r.path) {
+		content, err = yaml.Marshal(r)
+	} else {
+		content, err = json.MarshalIndent(r, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to marshal fixture ! Caused by: %w", err)
+	}
+	err = os.WriteFile(r.path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to write fixture file %s ! Caused by: %w", r.path, err)
+	}
+	return
+}
+
+// Mock replaying recorded executions without running anything, each one taking its recorded duration.
+// Calls are matched regardless of their order, so executions recorded from parallel sequences can be replayed,
+// identical calls are replayed in recorded order.
+// Commands are matched once their secrets are redacted, and replayed outputs keep secrets redacted.
+func (r *Recording) Mock(t *testing.T) *Mock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := NewMock(t)
+	for _, e := range r.Executions {
+		c := m.Expect(e.Argv...).Env(e.Env...).Stdin(e.Stdin).Return(e.Rc, e.Stdout, e.Stderr).Delay(e.Duration).Once()
+		c.redacted = true
+	}
+	return m
+}
+
+// Load a fixture file and return a Mock replaying it.
+func ReplayMock(t *testing.T, path string) (*Mock, error) {
+	r, err := LoadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Mock(t), nil
+}
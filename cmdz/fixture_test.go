@@ -0,0 +1,128 @@
+package cmdz
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	for _, ext := range []string{"json", "yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixture."+ext)
+
+			newSeq := func() (Executer, Executer, Executer) {
+				echo := Cmd("echo", "foo")
+				env := Sh("echo $VALUE ; >&2 echo bar ; exit 3").AddEnv("VALUE", "baz")
+				cat := Cmd("cat").SetInput(strings.NewReader("input"))
+				return echo, env, cat
+			}
+
+			rec := NewRecording(path)
+			echo, env, cat := newSeq()
+			rc, err := Serial(echo, env, cat).Record(rec).BlockRun()
+			require.NoError(t, err)
+			assert.Equal(t, 0, rc)
+			require.NoError(t, rec.Save())
+			require.Len(t, rec.Executions, 3)
+			assert.Equal(t, []string{"sh", "-c", "echo $VALUE ; >&2 echo bar ; exit 3"}, rec.Executions[1].Argv)
+			assert.Equal(t, []string{"VALUE=baz"}, rec.Executions[1].Env)
+			assert.Equal(t, 3, rec.Executions[1].Rc)
+
+			m, err := ReplayMock(t, path)
+			require.NoError(t, err)
+			echo, env, cat = newSeq()
+			rc, err = Serial(echo, env, cat).Mock(m).BlockRun()
+			require.NoError(t, err)
+			assert.Equal(t, 0, rc)
+			assert.Equal(t, "foo\n", echo.StdoutRecord())
+			assert.Equal(t, "baz\n", env.StdoutRecord())
+			assert.Equal(t, "bar\n", env.StderrRecord())
+			assert.Equal(t, []int{3}, env.ResultCodes())
+			assert.Equal(t, "input", cat.StdoutRecord())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplay_Mismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	rec := NewRecording(path)
+	_, err := Cmd("echo", "foo", "bar").Record(rec).BlockRun()
+	require.NoError(t, err)
+	require.NoError(t, rec.Save())
+
+	m, err := ReplayMock(t, path)
+	require.NoError(t, err)
+	_, err = Cmd("echo", "foo", "baz").Mock(m).BlockRun()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "  foo\n- bar\n+ baz\n")
+}
+
+func TestRecordAndReplay_Secrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	newCmd := func() Executer {
+		return Sh("echo $TOKEN; cat").AddEnv("TOKEN", "s3cr3t").SetInput(strings.NewReader("s3cr3t"))
+	}
+
+	rec := NewRecording(path)
+	// Secrets declared on sequence apply to execs added later
+	s := Serial()
+	s.Secrets("s3cr3t")
+	e := newCmd()
+	s.Add(e)
+	_, err := s.Record(rec).BlockRun()
+	require.NoError(t, err)
+	require.NoError(t, rec.Save())
+	assert.Equal(t, "s3cr3t\ns3cr3t", strings.TrimSpace(e.(*cmdz).stdoutRecord.String()))
+	assert.Equal(t, redactedSecret+"\n"+redactedSecret, e.StdoutRecord())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "s3cr3t")
+	assert.Contains(t, string(content), "TOKEN="+redactedSecret)
+
+	m, err := ReplayMock(t, path)
+	require.NoError(t, err)
+	e = newCmd()
+	rc, err := e.Secrets("s3cr3t").Mock(m).BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	m.AssertExpectations(t)
+}
+
+func TestRecordAndReplay_Parallel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	newSeq := func() (Executer, Executer, Executer) {
+		return Sh("sleep 0.05; echo foo"), Cmd("echo", "bar"), Cmd("echo", "baz")
+	}
+
+	rec := NewRecording(path)
+	foo, bar, baz := newSeq()
+	_, err := Parallel(foo, bar, baz).Record(rec).BlockRun()
+	require.NoError(t, err)
+	require.NoError(t, rec.Save())
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "mutex")
+
+	for i := 0; i < 5; i++ {
+		m, err := ReplayMock(t, path)
+		require.NoError(t, err)
+		foo, bar, baz = newSeq()
+		start := time.Now()
+		_, err = Parallel(foo, bar, baz).Mock(m).BlockRun()
+		require.NoError(t, err)
+		// Recorded durations are replayed
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, "foo\n", foo.StdoutRecord())
+		assert.Equal(t, "bar\n", bar.StdoutRecord())
+		assert.Equal(t, "baz\n", baz.StdoutRecord())
+		m.AssertExpectations(t)
+	}
+}
@@ -1,6 +1,13 @@
 package cmdz
 
-import "time"
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mxbossard/utilz/printz"
+)
 
 type (
 	basicFormat[O any] struct {
@@ -17,6 +24,11 @@ func (f *basicFormat[O]) Format() (out O, err error) {
 	stdout := []byte(f.Executer.StdoutRecord())
 	stderr := []byte(f.Executer.StderrRecord())
 	o, err := f.outFormatter(rc, stdout, stderr)
+	if fail, ok := err.(failure); ok && fail.reporter == nil {
+		// Decoders do not know the executed command
+		fail.reporter = f.Executer
+		err = fail
+	}
 	return o, err
 }
 
@@ -31,16 +43,116 @@ func (e *basicFormat[O]) CombinedOutputs() Formatter[O] {
 	return e
 }
 
+func (e *basicFormat[O]) Mock(m *Mock) Formatter[O] {
+	e.Executer = e.Executer.Mock(m)
+	return e
+}
+
+func (e *basicFormat[O]) Record(r *Recording) Formatter[O] {
+	e.Executer = e.Executer.Record(r)
+	return e
+}
+
+func (e *basicFormat[O]) InProcess(fn ProcessFunc) Formatter[O] {
+	e.Executer = e.Executer.InProcess(fn)
+	return e
+}
+
 func (e *basicFormat[O]) Retries(count, delayInMs int) Formatter[O] {
 	e.Executer = e.Executer.Retries(count, delayInMs)
 	return e
 }
 
+func (e *basicFormat[O]) Backoff(factor float64, maxDelay time.Duration, jitter float64) Formatter[O] {
+	e.Executer = e.Executer.Backoff(factor, maxDelay, jitter)
+	return e
+}
+
+func (e *basicFormat[O]) RetryTimeout(total time.Duration) Formatter[O] {
+	e.Executer = e.Executer.RetryTimeout(total)
+	return e
+}
+
+func (e *basicFormat[O]) RetryIf(predicate RetryPredicate) Formatter[O] {
+	e.Executer = e.Executer.RetryIf(predicate)
+	return e
+}
+
 func (e *basicFormat[O]) Timeout(duration time.Duration) Formatter[O] {
 	e.Executer = e.Executer.Timeout(duration)
 	return e
 }
 
+func (e *basicFormat[O]) Termination(signal syscall.Signal, grace time.Duration) Formatter[O] {
+	e.Executer = e.Executer.Termination(signal, grace)
+	return e
+}
+
+func (e *basicFormat[O]) Context(ctx context.Context) Formatter[O] {
+	e.Executer = e.Executer.Context(ctx)
+	return e
+}
+
+func (e *basicFormat[O]) Dir(path string) Formatter[O] {
+	e.Executer = e.Executer.Dir(path)
+	return e
+}
+
+func (e *basicFormat[O]) Nice(n int) Formatter[O] {
+	e.Executer = e.Executer.Nice(n)
+	return e
+}
+
+func (e *basicFormat[O]) Umask(mask os.FileMode) Formatter[O] {
+	e.Executer = e.Executer.Umask(mask)
+	return e
+}
+
+func (e *basicFormat[O]) Limits(limits ResourceLimits) Formatter[O] {
+	e.Executer = e.Executer.Limits(limits)
+	return e
+}
+
+func (e *basicFormat[O]) InheritEnv(allowed ...string) Formatter[O] {
+	e.Executer = e.Executer.InheritEnv(allowed...)
+	return e
+}
+
+func (e *basicFormat[O]) CleanEnv() Formatter[O] {
+	e.Executer = e.Executer.CleanEnv()
+	return e
+}
+
+func (e *basicFormat[O]) Secrets(values ...string) Formatter[O] {
+	e.Executer = e.Executer.Secrets(values...)
+	return e
+}
+
+func (e *basicFormat[O]) Hooks(hooks ...Hooks) Formatter[O] {
+	e.Executer = e.Executer.Hooks(hooks...)
+	return e
+}
+
+func (e *basicFormat[O]) DryRun(printer printz.Printer) Formatter[O] {
+	e.Executer = e.Executer.DryRun(printer)
+	return e
+}
+
+func (e *basicFormat[O]) DryRunRc(rc int) Formatter[O] {
+	e.Executer = e.Executer.DryRunRc(rc)
+	return e
+}
+
+func (e *basicFormat[O]) RecordLimit(maxBytes int) Formatter[O] {
+	e.Executer = e.Executer.RecordLimit(maxBytes)
+	return e
+}
+
+func (e *basicFormat[O]) SpillRecords() Formatter[O] {
+	e.Executer = e.Executer.SpillRecords()
+	return e
+}
+
 func (e *basicFormat[O]) AddEnv(key, value string) Formatter[O] {
 	e.Executer = e.Executer.AddEnv(key, value)
 	return e
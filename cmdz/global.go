@@ -4,7 +4,10 @@ import (
 	//"bufio"
 	"context"
 	//"fmt"
+	"sync"
+	"sync/atomic"
 
+	"github.com/mxbossard/utilz/errorz"
 	"github.com/mxbossard/utilz/promiz"
 )
 
@@ -20,6 +23,37 @@ func AsyncRunAll(execs ...Executer) *execsPromise {
 	return p
 }
 
+// Run e once with ctx scoped to this run. The executer is left untouched, so later runs ignore ctx.
+func asyncRunCtx(ctx context.Context, e Executer) *execPromise {
+	return promiz.New(func(resolve func(int), reject func(error)) {
+		rc, err := e.blockRunCtx(ctx)
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(rc)
+	})
+}
+
+// Config with ctx scoped to a run, if cfg has no ctx of its own.
+func withRunCtx(cfg *config, ctx context.Context) *config {
+	if ctx != nil && cfg.ctx == nil {
+		cfg.ctx = ctx
+	}
+	return cfg
+}
+
+// AsyncRunAllCtx run execs concurrently with ctx. Once ctx is done, running executions are canceled and the promise reject with ctx error.
+// Execs configured with their own Context keep it.
+func AsyncRunAllCtx(ctx context.Context, execs ...Executer) *execsPromise {
+	var promises []*promiz.Promise[int]
+	for _, e := range execs {
+		p := asyncRunCtx(ctx, e)
+		promises = append(promises, p)
+	}
+	return awaitSettled(promiz.All[int](ctx, promises...), promises...)
+}
+
 func WaitAllResults(p *execsPromise) (*[]int, error) {
 	ctx := context.Background()
 	return p.Await(ctx)
@@ -37,6 +71,32 @@ func AsyncRunBest(execs ...Executer) *promiz.Promise[promiz.BestResults[int]] {
 	return p
 }
 
+// AsyncRunBestCtx run execs concurrently with ctx. Once ctx is done, running executions are canceled and the promise reject with ctx error.
+// Execs configured with their own Context keep it.
+func AsyncRunBestCtx(ctx context.Context, execs ...Executer) *promiz.Promise[promiz.BestResults[int]] {
+	var promises []*promiz.Promise[int]
+	for _, e := range execs {
+		p := asyncRunCtx(ctx, e)
+		promises = append(promises, p)
+	}
+	return awaitSettled(promiz.Best[int](ctx, promises...), promises...)
+}
+
+// Settle like p once all runs settled, so no execution is still running when the result is available.
+func awaitSettled[T any](p *promiz.Promise[T], runs ...*execPromise) *promiz.Promise[T] {
+	return promiz.New(func(resolve func(T), reject func(error)) {
+		for _, run := range runs {
+			_, _ = run.Await(context.Background())
+		}
+		result, err := p.Await(context.Background())
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(*result)
+	})
+}
+
 func WaitBestResults(p *promiz.Promise[promiz.BestResults[int]]) (*promiz.BestResults[int], error) {
 	ctx := context.Background()
 	br, err := p.Await(ctx)
@@ -63,6 +123,9 @@ func Succeed(resultCodes ...int) bool {
 }
 
 func blockParallelRunAll(forkCount int, execs ...Executer) ([]int, error) {
+	if forkCount > 0 && forkCount < len(execs) {
+		return blockBoundedRunAll(forkCount, execs...)
+	}
 	p := AsyncRunAll(execs...)
 	statuses, err := WaitAllResults(p)
 	if err != nil {
@@ -72,7 +135,67 @@ func blockParallelRunAll(forkCount int, execs ...Executer) ([]int, error) {
 	return *statuses, nil
 }
 
+// Run execs concurrently with at most forkCount executions running at once.
+// No execution is started anymore once one returned an error.
+func blockBoundedRunAll(forkCount int, execs ...Executer) ([]int, error) {
+	statuses, errs := blockBoundedRun(forkCount, true, execs...)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
+// Run all execs concurrently with at most forkCount executions running at once.
+// Errors are aggregated once all executions ended.
+func blockBoundedRunBest(forkCount int, execs ...Executer) ([]int, error) {
+	statuses, errs := blockBoundedRun(forkCount, false, execs...)
+	var errors []error
+	for _, err := range errs {
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if len(errors) > 0 {
+		return nil, errorz.NewAggregated(errors...)
+	}
+	return statuses, nil
+}
+
+// Run execs concurrently with at most forkCount executions running at once.
+// If stopOnError, no execution is started anymore once one returned an error.
+func blockBoundedRun(forkCount int, stopOnError bool, execs ...Executer) ([]int, []error) {
+	statuses := make([]int, len(execs))
+	errs := make([]error, len(execs))
+	slots := make(chan struct{}, forkCount)
+	var failed atomic.Bool
+	wg := sync.WaitGroup{}
+	for i, exec := range execs {
+		slots <- struct{}{}
+		if stopOnError && failed.Load() {
+			break
+		}
+		wg.Add(1)
+		go func(i int, exec Executer) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			statuses[i], errs[i] = exec.BlockRun()
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}(i, exec)
+	}
+	wg.Wait()
+	return statuses, errs
+}
+
 func blockParallelRunBest(forkCount int, execs ...Executer) ([]int, error) {
+	if forkCount > 0 && forkCount < len(execs) {
+		return blockBoundedRunBest(forkCount, execs...)
+	}
 	p := AsyncRunBest(execs...)
 	br, err := WaitBestResults(p)
 	if err != nil {
@@ -85,6 +208,9 @@ func blockParallelRunBest(forkCount int, execs ...Executer) ([]int, error) {
 func blockParallel(failFast bool, forkCount int, execs ...Executer) (status int, err error) {
 	if failFast {
 		for _, exec := range execs {
+			if m, ok := exec.(*muxedExec); ok {
+				exec = m.Executer
+			}
 			if c, ok := exec.(*cmdz); ok {
 				c.errorOnFailure = true
 			}
@@ -140,3 +266,19 @@ func blockOr(execs ...Executer) (status int, err error) {
 	}
 	return
 }
+
+// Channel closed when ctx is done, nil if ctx is nil.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
+// Error of ctx if it is done.
+func canceled(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -6,8 +6,10 @@ import (
 	//"context"
 	//"log"
 	//"os/exec"
+	"context"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -235,3 +237,49 @@ func TestBlockParallel(t *testing.T) {
 	assert.Equal(t, "", e2.StdoutRecord())
 
 }
+
+func TestAsyncRunAllCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e1 := Cmd("sleep", "5")
+	e2 := Cmd("sleep", "0.2")
+	p := AsyncRunAllCtx(ctx, e1, e2)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := WaitAllResults(p)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 3*time.Second)
+
+	// Canceled ctx does not stick to the execs
+	rc, err := e2.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	statuses, err := WaitAllResults(AsyncRunAllCtx(ctx, Cmd("true"), Cmd("false")))
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, *statuses)
+}
+
+func TestAsyncRunBestCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e1 := Cmd("sleep", "5")
+	e2 := Cmd("true")
+	p := AsyncRunBestCtx(ctx, e1, e2)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	br, err := WaitBestResults(p)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Nil(t, br)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Canceled ctx does not stick to the execs
+	s := Serial(Cmd("true"), Cmd("true"))
+	br, err = WaitBestResults(AsyncRunBestCtx(ctx, s))
+	assert.Nil(t, br)
+	assert.ErrorIs(t, err, context.Canceled)
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+}
@@ -0,0 +1,502 @@
+package cmdz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mxbossard/utilz/collectionz"
+	"github.com/mxbossard/utilz/printz"
+	"github.com/mxbossard/utilz/promiz"
+)
+
+type graphNode struct {
+	name string
+	exec Executer
+	deps []string
+	ran  bool
+}
+
+// graphSeq execute nodes of a dependency graph (DAG) concurrently, each node once all its dependencies succeeded.
+type graphSeq struct {
+	*seq
+
+	forkCount int
+	nodes     []*graphNode
+}
+
+func (e *graphSeq) SetInput(stdin io.Reader) Executer {
+	e.seq.setStdin(stdin)
+	return e
+}
+
+func (e *graphSeq) SetStdout(stdout io.Writer) Executer {
+	e.seq.setStdout(stdout)
+	return e
+}
+
+func (e *graphSeq) SetStderr(stderr io.Writer) Executer {
+	e.seq.setStderr(stderr)
+	return e
+}
+
+func (e *graphSeq) SetOutputs(stdout, stderr io.Writer) Executer {
+	if e.pipedOutput {
+		log.Fatal("Output is piped cannot change it !")
+	}
+	e.config.stdout = stdout
+	e.config.stderr = stderr
+	for _, o := range e.execs {
+		o.SetOutputs(stdout, stderr)
+	}
+	return e
+}
+
+// ----- Configurer methods -----
+
+func (s *graphSeq) ErrorOnFailure(enable bool) Executer {
+	s.config.errorOnFailure = enable
+	return s
+}
+
+func (s *graphSeq) Retries(count, delayInMs int) Executer {
+	s.config.retries = count
+	s.config.retryDelayInMs = delayInMs
+	return s
+}
+
+func (s *graphSeq) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	s.config.retryFactor = factor
+	s.config.retryMaxDelay = maxDelay
+	s.config.retryJitter = jitter
+	return s
+}
+
+func (s *graphSeq) RetryTimeout(total time.Duration) Executer {
+	s.config.retryMaxDuration = total
+	return s
+}
+
+func (s *graphSeq) RetryIf(predicate RetryPredicate) Executer {
+	s.config.retryPredicate = predicate
+	return s
+}
+
+func (s *graphSeq) Timeout(duration time.Duration) Executer {
+	s.config.timeout = duration
+	return s
+}
+
+func (s *graphSeq) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	s.config.termSignal = signal
+	s.config.termGrace = grace
+	return s
+}
+
+func (s *graphSeq) Context(ctx context.Context) Executer {
+	s.config.ctx = ctx
+	return s
+}
+
+func (s *graphSeq) Dir(path string) Executer {
+	s.config.dir = path
+	return s
+}
+
+func (s *graphSeq) Nice(n int) Executer {
+	s.config.nice = n
+	return s
+}
+
+func (s *graphSeq) Umask(mask os.FileMode) Executer {
+	s.config.umask = &mask
+	return s
+}
+
+func (s *graphSeq) Limits(limits ResourceLimits) Executer {
+	s.config.limits = limits
+	return s
+}
+
+func (s *graphSeq) InheritEnv(allowed ...string) Executer {
+	if len(allowed) == 0 {
+		s.config.envMode = envInheritAll
+	} else {
+		s.config.envMode = envInheritAllowed
+	}
+	s.config.envAllowed = allowed
+	return s
+}
+
+func (s *graphSeq) CleanEnv() Executer {
+	s.config.envMode = envClean
+	s.config.envAllowed = nil
+	return s
+}
+
+func (s *graphSeq) Secrets(values ...string) Executer {
+	s.config.secrets = append(s.config.secrets, values...)
+	for _, e := range s.execs {
+		e.Secrets(values...)
+	}
+	return s
+}
+
+func (s *graphSeq) Hooks(hooks ...Hooks) Executer {
+	s.config.hooks = append(s.config.hooks, hooks...)
+	return s
+}
+
+func (s *graphSeq) DryRun(printer printz.Printer) Executer {
+	s.config.dryRun = dryRunPrinter(printer)
+	return s
+}
+
+func (s *graphSeq) DryRunRc(rc int) Executer {
+	s.config.dryRunRc = &rc
+	return s
+}
+
+func (s *graphSeq) RecordLimit(maxBytes int) Executer {
+	s.config.recordLimit = maxBytes
+	return s
+}
+
+func (s *graphSeq) SpillRecords() Executer {
+	s.config.spillRecords = true
+	return s
+}
+
+func (s *graphSeq) AddEnv(key, value string) Executer {
+	for _, e := range s.execs {
+		e.AddEnv(key, value)
+	}
+	return s
+}
+
+func (s *graphSeq) AddEnviron(environ ...string) Executer {
+	for _, e := range s.execs {
+		e.AddEnviron(environ...)
+	}
+	return s
+}
+
+func (s *graphSeq) AddArgs(args ...string) Executer {
+	for _, e := range s.execs {
+		e.AddArgs(args...)
+	}
+	return s
+}
+
+func (s *graphSeq) CombinedOutputs() Executer {
+	for _, e := range s.execs {
+		e.CombinedOutputs()
+	}
+	return s
+}
+
+func (s *graphSeq) Mock(m *Mock) Executer {
+	s.config.mock = m
+	return s
+}
+
+func (s *graphSeq) Record(r *Recording) Executer {
+	s.config.recording = r
+	return s
+}
+
+func (s *graphSeq) InProcess(fn ProcessFunc) Executer {
+	s.config.inProcess = fn
+	return s
+}
+
+// FailFast stop starting new nodes as soon as one node failed. Otherwise all nodes whose dependencies succeeded are run.
+func (s *graphSeq) FailFast(enabled bool) *graphSeq {
+	s.failFast = enabled
+	return s
+}
+
+// Fork limit the count of nodes running at once. Unlimited if count <= 0.
+func (s *graphSeq) Fork(count int) *graphSeq {
+	s.forkCount = count
+	return s
+}
+
+// Node add a named node executed once all the nodes it depends on succeeded.
+func (s *graphSeq) Node(name string, exec Executer, deps ...string) *graphSeq {
+	s.nodes = append(s.nodes, &graphNode{name: name, exec: exec, deps: deps})
+	s.execs = append(s.execs, exec)
+	s.inners = s.execs
+	s.outers = s.execs
+	return s
+}
+
+func (s *graphSeq) node(name string) *graphNode {
+	for _, n := range s.nodes {
+		if n.name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// Exec return the Executer of node name, or nil if no such node exists.
+func (s *graphSeq) Exec(name string) Executer {
+	if n := s.node(name); n != nil {
+		return n.exec
+	}
+	return nil
+}
+
+// Skipped return the nodes not run during last run because a dependency failed or the graph failed fast.
+func (s *graphSeq) Skipped() (names []string) {
+	for _, n := range s.nodes {
+		if !n.ran {
+			names = append(names, n.name)
+		}
+	}
+	return
+}
+
+// Validate check all dependencies exist and the graph contains no cycle.
+func (s *graphSeq) Validate() error {
+	names := map[string]bool{}
+	for _, n := range s.nodes {
+		if names[n.name] {
+			return fmt.Errorf("Duplicate graph node: %s !", n.name)
+		}
+		names[n.name] = true
+	}
+	for _, n := range s.nodes {
+		for _, d := range n.deps {
+			if !names[d] {
+				return fmt.Errorf("Graph node %s depends on unknown node: %s !", n.name, d)
+			}
+		}
+	}
+
+	// Depth first search keeping the current path to report the cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	states := map[string]int{}
+	var path []string
+	var visit func(n *graphNode) error
+	visit = func(n *graphNode) error {
+		switch states[n.name] {
+		case visited:
+			return nil
+		case visiting:
+			start := slices.Index(path, n.name)
+			cycle := append(path[start:], n.name)
+			return fmt.Errorf("Dependency cycle detected in graph: %s !", strings.Join(cycle, " -> "))
+		}
+		states[n.name] = visiting
+		path = append(path, n.name)
+		for _, d := range n.deps {
+			if err := visit(s.node(d)); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		states[n.name] = visited
+		return nil
+	}
+	for _, n := range s.nodes {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s graphSeq) String() string {
+	var lines []string
+	for _, n := range s.nodes {
+		line := fmt.Sprintf("%s: %s", n.name, n.exec)
+		if len(n.deps) > 0 {
+			line += fmt.Sprintf(" (after %s)", strings.Join(n.deps, ", "))
+		}
+		lines = append(lines, line)
+	}
+	return redact(strings.Join(lines, "\n"), s.secrets())
+}
+
+func (s graphSeq) ReportError() string {
+	errors := collectionz.Map[Executer, string](&s.seq.execs, func(e Executer) string {
+		return e.ReportError()
+	})
+	return strings.Join(errors, "\n")
+}
+
+func (s *graphSeq) init() {
+	for _, exec := range s.seq.execs {
+		exec.init()
+	}
+}
+
+func (s *graphSeq) reset() {
+	s.seq.reset()
+	for _, n := range s.nodes {
+		n.ran = false
+	}
+}
+
+func (s *graphSeq) BlockRun() (rc int, err error) {
+	return s.blockRunCtx(nil)
+}
+
+func (s *graphSeq) blockRunCtx(ctx context.Context) (rc int, err error) {
+	if err = s.Validate(); err != nil {
+		return -1, err
+	}
+	mergedConfig := dryRunSeq(withRunCtx(mergeConfigs(&s.config, s.fallbackConfig), ctx), "graph")
+	for _, exec := range s.seq.execs {
+		exec.fallback(mergedConfig)
+	}
+	s.reset()
+	forkCount := s.forkCount
+	if mergedConfig.dryRun != nil {
+		// Print the simulated executions in order
+		forkCount = 1
+	}
+	rc, err = s.blockRun(forkCount)
+	s.status = rc
+	return
+}
+
+type graphResult struct {
+	node *graphNode
+	rc   int
+	err  error
+}
+
+// Schedule nodes as soon as their dependencies succeeded. Return the first failing node result in nodes order.
+func (s *graphSeq) blockRun(forkCount int) (rc int, err error) {
+	pending := map[string]int{}
+	dependents := map[string][]*graphNode{}
+	var ready []*graphNode
+	for _, n := range s.nodes {
+		pending[n.name] = len(n.deps)
+		for _, d := range n.deps {
+			dependents[d] = append(dependents[d], n)
+		}
+		if len(n.deps) == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	results := make(chan graphResult)
+	rcs := map[string]int{}
+	running := 0
+	stop := false
+	for {
+		for !stop && len(ready) > 0 && (forkCount <= 0 || running < forkCount) {
+			n := ready[0]
+			ready = ready[1:]
+			n.ran = true
+			running++
+			go func(n *graphNode) {
+				rc, err := n.exec.BlockRun()
+				results <- graphResult{n, rc, err}
+			}(n)
+		}
+		if running == 0 {
+			break
+		}
+
+		r := <-results
+		running--
+		if f, ok := r.err.(failure); ok {
+			r.rc = f.Rc
+		} else if r.err != nil {
+			// Wait running nodes but do not start new ones
+			if err == nil {
+				err = r.err
+			}
+			stop = true
+			continue
+		}
+		rcs[r.node.name] = r.rc
+		if r.rc != 0 {
+			// Dependents of a failed node are never ready
+			stop = stop || s.failFast
+			continue
+		}
+		for _, d := range dependents[r.node.name] {
+			pending[d.name]--
+			if pending[d.name] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	for _, n := range s.nodes {
+		if rc, ok := rcs[n.name]; ok && rc != 0 {
+			return rc, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *graphSeq) AsyncRun() *execPromise {
+	return promiz.New(func(resolve func(int), reject func(error)) {
+		rc, err := s.BlockRun()
+		if err != nil {
+			reject(err)
+		}
+		resolve(rc)
+	})
+}
+
+func (e *graphSeq) ResultCodes() (codes []int) {
+	for _, exec := range e.execs {
+		codes = append(codes, exec.ResultCodes()...)
+	}
+	return
+}
+
+func (e *graphSeq) ExitCode() int {
+	codes := e.ResultCodes()
+	return codes[len(codes)-1]
+}
+
+func (e *graphSeq) StartTimes() (times []time.Time) {
+	for _, exec := range e.execs {
+		times = append(times, exec.StartTimes()...)
+	}
+	return
+}
+
+func (e *graphSeq) StartTime() time.Time {
+	var first time.Time
+	for _, t := range e.StartTimes() {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+	return first
+}
+
+func (e *graphSeq) Durations() (durations []time.Duration) {
+	for _, exec := range e.execs {
+		durations = append(durations, exec.Durations()...)
+	}
+	return
+}
+
+// Duration from the first node start to the last node end.
+func (e *graphSeq) Duration() time.Duration {
+	return e.report().Duration
+}
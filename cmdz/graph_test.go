@@ -0,0 +1,158 @@
+package cmdz
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraph_Validate(t *testing.T) {
+	g := Graph().Node("a", Cmd("true")).Node("b", Cmd("true"), "a")
+	assert.NoError(t, g.Validate())
+
+	g = Graph().Node("a", Cmd("true"), "c")
+	assert.EqualError(t, g.Validate(), "Graph node a depends on unknown node: c !")
+
+	g = Graph().Node("a", Cmd("true")).Node("a", Cmd("true"))
+	assert.EqualError(t, g.Validate(), "Duplicate graph node: a !")
+
+	g = Graph().
+		Node("a", Cmd("true")).
+		Node("b", Cmd("true"), "a", "d").
+		Node("c", Cmd("true"), "b").
+		Node("d", Cmd("true"), "c")
+	assert.EqualError(t, g.Validate(), "Dependency cycle detected in graph: b -> d -> c -> b !")
+	rc, err := g.BlockRun()
+	assert.Error(t, err)
+	assert.Equal(t, -1, rc)
+}
+
+func TestGraph_Order(t *testing.T) {
+	g := Graph().
+		Node("d", Sh("echo d"), "b", "c").
+		Node("b", Sh("sleep 0.05; echo b"), "a").
+		Node("c", Sh("echo c"), "a").
+		Node("a", Sh("echo a"))
+	rc, err := g.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Empty(t, g.Skipped())
+	assert.Equal(t, "d\nb\nc\na\n", g.StdoutRecord())
+
+	end := func(e Executer) time.Time {
+		return e.StartTime().Add(e.Duration())
+	}
+	a, b, c, d := g.Exec("a"), g.Exec("b"), g.Exec("c"), g.Exec("d")
+	require.NotNil(t, b)
+	assert.Equal(t, []int{0}, b.ResultCodes())
+	assert.GreaterOrEqual(t, b.Duration(), 50*time.Millisecond)
+	assert.True(t, b.StartTime().After(end(a)))
+	assert.True(t, c.StartTime().After(end(a)))
+	assert.True(t, c.StartTime().Before(end(b)))
+	assert.True(t, d.StartTime().After(end(b)))
+	assert.True(t, d.StartTime().After(end(c)))
+	assert.GreaterOrEqual(t, g.Duration(), 50*time.Millisecond)
+	assert.Nil(t, g.Exec("z"))
+}
+
+func TestGraph_Fork(t *testing.T) {
+	g := Graph().Fork(2)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		g.Node(name, Cmd("sleep", "0.05"))
+	}
+	start := time.Now()
+	rc, err := g.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, 2, maxConcurrency(g.Exec("a"), g.Exec("b"), g.Exec("c"), g.Exec("d")))
+}
+
+// Max count of execs running at once, observed from their start times and durations.
+func maxConcurrency(execs ...Executer) (max int) {
+	for _, e := range execs {
+		running := 0
+		for _, other := range execs {
+			if !other.StartTime().After(e.StartTime()) && other.StartTime().Add(other.Duration()).After(e.StartTime()) {
+				running++
+			}
+		}
+		if running > max {
+			max = running
+		}
+	}
+	return
+}
+
+func TestGraph_Failures(t *testing.T) {
+	newGraph := func() *graphSeq {
+		return Graph().
+			Node("a", Sh("exit 3")).
+			Node("b", Sh("sleep 0.05"), "a").
+			Node("c", Sh("sleep 0.05")).
+			Node("d", Sh("true"), "c")
+	}
+
+	// Best effort run all nodes not depending on a failure
+	g := newGraph()
+	rc, err := g.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+	assert.Equal(t, []string{"b"}, g.Skipped())
+	assert.Equal(t, []int{0}, g.Exec("d").ResultCodes())
+
+	// Fail fast do not start nodes anymore after a failure
+	g = newGraph().FailFast(true)
+	rc, err = g.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+	assert.Equal(t, []string{"b", "d"}, g.Skipped())
+	assert.Equal(t, []int{0}, g.Exec("c").ResultCodes())
+}
+
+func TestGraph_Composable(t *testing.T) {
+	out := &strings.Builder{}
+	g := Graph().
+		Node("a", And(Sh("echo a1"), Sh("echo a2"))).
+		Node("b", Or(Sh("exit 1"), Sh("echo b")), "a")
+	s := Serial(g, Sh("echo end"))
+	s.SetStdout(out)
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "a1\na2\nb\nend\n", out.String())
+	assert.Equal(t, []int{0, 0, 1, 0}, g.ResultCodes())
+
+	r := Report(g)
+	assert.Equal(t, GraphKind, r.Kind)
+	require.Len(t, r.Children, 2)
+	assert.Equal(t, OrKind, r.Children[1].Kind)
+}
+
+func TestParallel_Fork(t *testing.T) {
+	p := Parallel(Cmd("sleep", "0.05"), Cmd("sleep", "0.05"), Cmd("sleep", "0.05")).Fork(2)
+	start := time.Now()
+	rc, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Len(t, p.ResultCodes(), 3)
+}
+
+func TestParallelRunBest_Fork(t *testing.T) {
+	execs := []Executer{Cmd("sleep", "0.05"), Sh("sleep 0.05; exit 2"), Cmd("sleep", "0.05"), Cmd("sleep", "0.05")}
+	statuses, err := blockParallelRunBest(2, execs...)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 0, 0}, statuses)
+	assert.Equal(t, 2, maxConcurrency(execs...))
+
+	// All execs are run despite errors
+	execs = []Executer{Cmd("sleep", "0.05"), Cmd("not_existing_binary_zzz"), Cmd("sleep", "0.05")}
+	_, err = blockParallelRunBest(1, execs...)
+	assert.Error(t, err)
+	assert.Equal(t, []int{0}, execs[2].ResultCodes())
+	assert.Equal(t, 1, maxConcurrency(execs[0], execs[2]))
+}
@@ -0,0 +1,84 @@
+package cmdz
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mxbossard/utilz/zlog"
+)
+
+var (
+	logger = zlog.New()
+
+	defaultHooksLock sync.RWMutex
+	defaultHooks     []Hooks
+)
+
+// Hooks observe command executions. Nil callbacks are ignored.
+type Hooks struct {
+	// Called before each attempt starts.
+	BeforeStart func(e Executer)
+	// Called after each attempt ended, rc is -1 if the attempt timed out.
+	AfterAttempt func(e Executer, rc int, duration time.Duration)
+	// Called once if the execution failed to run or ended with a non zero result code after all attempts.
+	OnFailure func(e Executer, err error)
+}
+
+// SetDefaultHooks replace the hooks called for all executions, after configured ones.
+func SetDefaultHooks(hooks ...Hooks) {
+	defaultHooksLock.Lock()
+	defer defaultHooksLock.Unlock()
+	defaultHooks = hooks
+}
+
+// Configured hooks followed by default hooks.
+func hookChain(cfg *config) []Hooks {
+	defaultHooksLock.RLock()
+	defer defaultHooksLock.RUnlock()
+	if len(defaultHooks) == 0 {
+		return cfg.hooks
+	}
+	return append(append([]Hooks(nil), cfg.hooks...), defaultHooks...)
+}
+
+func beforeStart(hooks []Hooks, e Executer) {
+	for _, h := range hooks {
+		if h.BeforeStart != nil {
+			h.BeforeStart(e)
+		}
+	}
+}
+
+func afterAttempt(hooks []Hooks, e Executer, rc int, duration time.Duration) {
+	for _, h := range hooks {
+		if h.AfterAttempt != nil {
+			h.AfterAttempt(e, rc, duration)
+		}
+	}
+}
+
+func onFailure(hooks []Hooks, e Executer, err error) {
+	for _, h := range hooks {
+		if h.OnFailure != nil {
+			h.OnFailure(e, err)
+		}
+	}
+}
+
+// ZlogHooks log each attempt and failure at Debug level, timing attempts with a zlog perf timer.
+func ZlogHooks() Hooks {
+	timers := sync.Map{}
+	return Hooks{
+		BeforeStart: func(e Executer) {
+			timers.Store(e, logger.QualifiedDebugTimer("cmdz.exec", "exec", e).End)
+		},
+		AfterAttempt: func(e Executer, rc int, duration time.Duration) {
+			if end, ok := timers.LoadAndDelete(e); ok {
+				end.(func(...any))("rc", rc)
+			}
+		},
+		OnFailure: func(e Executer, err error) {
+			logger.Debug("cmdz.exec failed", "exec", e, "error", err)
+		},
+	}
+}
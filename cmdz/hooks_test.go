@@ -0,0 +1,136 @@
+package cmdz
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/zlog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hookCalls struct {
+	sync.Mutex
+	calls []string
+}
+
+func (c *hookCalls) add(format string, a ...any) {
+	c.Lock()
+	defer c.Unlock()
+	c.calls = append(c.calls, fmt.Sprintf(format, a...))
+}
+
+func (c *hookCalls) get() []string {
+	c.Lock()
+	defer c.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+func (c *hookCalls) hooks(label string) Hooks {
+	return Hooks{
+		BeforeStart: func(e Executer) {
+			c.add("%s before %s", label, e)
+		},
+		AfterAttempt: func(e Executer, rc int, duration time.Duration) {
+			c.add("%s after %s rc=%d", label, e, rc)
+		},
+		OnFailure: func(e Executer, err error) {
+			c.add("%s failure %s: %v", label, e, err != nil)
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	calls := &hookCalls{}
+	c := Cmd("true").Hooks(calls.hooks("a"), Hooks{})
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []string{"a before true", "a after true rc=0"}, calls.get())
+
+	calls = &hookCalls{}
+	c = Cmd("false").Retries(2, 0).Hooks(calls.hooks("a")).Hooks(calls.hooks("b"))
+	rc, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []string{
+		"a before false", "b before false", "a after false rc=1", "b after false rc=1",
+		"a before false", "b before false", "a after false rc=1", "b after false rc=1",
+		"a before false", "b before false", "a after false rc=1", "b after false rc=1",
+		"a failure false: true", "b failure false: true",
+	}, calls.get())
+
+	calls = &hookCalls{}
+	c = Cmd("/does/not/exist").Hooks(calls.hooks("a"))
+	_, err = c.BlockRun()
+	require.Error(t, err)
+	assert.Equal(t, []string{"a before /does/not/exist", "a after /does/not/exist rc=-1", "a failure /does/not/exist: true"}, calls.get())
+
+	calls = &hookCalls{}
+	c = Cmd("sleep", "1").Timeout(10 * time.Millisecond).Hooks(calls.hooks("a"))
+	_, err = c.BlockRun()
+	require.Error(t, err)
+	assert.Equal(t, []string{"a before sleep 1", "a after sleep 1 rc=-1", "a failure sleep 1: true"}, calls.get())
+}
+
+func TestHooks_InheritedBySequenceChildren(t *testing.T) {
+	calls := &hookCalls{}
+	s := Serial(Cmd("true").Hooks(calls.hooks("cmd")), Cmd("false")).Hooks(calls.hooks("seq"))
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []string{
+		"cmd before true", "seq before true", "cmd after true rc=0", "seq after true rc=0",
+		"seq before false", "seq after false rc=1", "seq failure false: true",
+	}, calls.get())
+
+	calls = &hookCalls{}
+	p := Parallel(Cmd("true"), Cmd("true")).Hooks(calls.hooks("par"))
+	_, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Len(t, calls.get(), 4)
+}
+
+func TestHooks_Default(t *testing.T) {
+	calls := &hookCalls{}
+	SetDefaultHooks(calls.hooks("default"))
+	defer SetDefaultHooks()
+
+	c := Cmd("true").Hooks(calls.hooks("cmd"))
+	_, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cmd before true", "default before true", "cmd after true rc=0", "default after true rc=0"}, calls.get())
+
+	SetDefaultHooks()
+	calls = &hookCalls{}
+	_, err = Cmd("true").Hooks(calls.hooks("cmd")).BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cmd before true", "cmd after true rc=0"}, calls.get())
+}
+
+func TestZlogHooks(t *testing.T) {
+	b := &strings.Builder{}
+	zlog.SetDefaultOutput(b)
+	defer zlog.SetDefaultOutput(os.Stderr)
+	threshold := zlog.GetLogLevelThreshold()
+	zlog.SetLogLevelThreshold(zlog.LevelDebug)
+	defer zlog.SetLogLevelThreshold(threshold)
+
+	_, err := Cmd("echo", "s3cr3t").Secrets("s3cr3t").Hooks(ZlogHooks()).BlockRun()
+	require.NoError(t, err)
+	_, err = Cmd("false").Hooks(ZlogHooks()).BlockRun()
+	require.NoError(t, err)
+
+	logged := b.String()
+	assert.Contains(t, logged, "cmdz.exec{")
+	assert.Contains(t, logged, "ended in")
+	assert.Contains(t, logged, "rc=0")
+	assert.Contains(t, logged, "rc=1")
+	assert.Contains(t, logged, "cmdz.exec failed")
+	assert.Contains(t, logged, "******")
+	assert.NotContains(t, logged, "s3cr3t")
+}
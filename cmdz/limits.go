@@ -0,0 +1,121 @@
+package cmdz
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ResourceLimits applied to executed processes and their descendants. Zero values are not limited.
+type ResourceLimits struct {
+	CpuTime   time.Duration // Max CPU time, rounded to seconds (RLIMIT_CPU)
+	Memory    uint64        // Max virtual memory in bytes (RLIMIT_AS)
+	OpenFiles uint64        // Max open file descriptors (RLIMIT_NOFILE)
+	Processes uint64        // Max processes of the user (RLIMIT_NPROC)
+}
+
+func (l ResourceLimits) isZero() bool {
+	return l == ResourceLimits{}
+}
+
+// Merge lower priority limits into higher priority, field by field.
+func mergeLimits(higher, lower ResourceLimits) ResourceLimits {
+	merged := higher
+	if merged.CpuTime == 0 {
+		merged.CpuTime = lower.CpuTime
+	}
+	if merged.Memory == 0 {
+		merged.Memory = lower.Memory
+	}
+	if merged.OpenFiles == 0 {
+		merged.OpenFiles = lower.OpenFiles
+	}
+	if merged.Processes == 0 {
+		merged.Processes = lower.Processes
+	}
+	return merged
+}
+
+// Rlimits of the limits, soft and hard limits are both set.
+func (l ResourceLimits) rlimits() (resources []int, values []uint64) {
+	if l.CpuTime > 0 {
+		seconds := max(1, uint64((l.CpuTime+time.Second-1)/time.Second))
+		resources, values = append(resources, unix.RLIMIT_CPU), append(values, seconds)
+	}
+	if l.Memory > 0 {
+		resources, values = append(resources, unix.RLIMIT_AS), append(values, l.Memory)
+	}
+	if l.OpenFiles > 0 {
+		resources, values = append(resources, unix.RLIMIT_NOFILE), append(values, l.OpenFiles)
+	}
+	if l.Processes > 0 {
+		resources, values = append(resources, unix.RLIMIT_NPROC), append(values, l.Processes)
+	}
+	return
+}
+
+// limitGate holds the wrapper shell until the limits are applied to its pid.
+type limitGate struct {
+	limits ResourceLimits
+	reader *os.File
+	writer *os.File
+}
+
+// Apply the limits to the started wrapper then release it.
+func (g *limitGate) apply(pid int) error {
+	defer g.close()
+	resources, values := g.limits.rlimits()
+	for i, resource := range resources {
+		limit := unix.Rlimit{Cur: values[i], Max: values[i]}
+		if err := unix.Prlimit(pid, resource, &limit, nil); err != nil {
+			return fmt.Errorf("Unable to apply process limits %+v ! Caused by: %w", g.limits, err)
+		}
+	}
+	return nil
+}
+
+func (g *limitGate) close() {
+	_ = g.reader.Close()
+	_ = g.writer.Close()
+}
+
+// Go cannot set rlimits, umask nor nice level of a child only, so the command is wrapped by a shell applying them
+// before exec-ing the original command. Rlimits are applied by the parent with prlimit while the shell wait on a pipe.
+func wrapProcessSettings(cmd *exec.Cmd, cfg *config) (*limitGate, error) {
+	if cmd.Err != nil || cfg.limits.isZero() && cfg.umask == nil && cfg.nice == 0 {
+		return nil, nil
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to apply process limits ! Caused by: %w", err)
+	}
+	var gate *limitGate
+	var script []string
+	if !cfg.limits.isZero() {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to apply process limits ! Caused by: %w", err)
+		}
+		gate = &limitGate{limits: cfg.limits, reader: reader, writer: writer}
+		fd := 3 + len(cmd.ExtraFiles)
+		cmd.ExtraFiles = append(cmd.ExtraFiles, reader)
+		// read return on EOF once the gate is closed
+		script = append(script, fmt.Sprintf("{ read _ <&%d; exec %d<&-; true; }", fd, fd))
+	}
+	if cfg.umask != nil {
+		script = append(script, fmt.Sprintf("umask %04o", *cfg.umask))
+	}
+	if cfg.nice != 0 {
+		script = append(script, fmt.Sprintf(`exec nice -n %d "$@"`, cfg.nice))
+	} else {
+		script = append(script, `exec "$@"`)
+	}
+	args := []string{"sh", "-c", strings.Join(script, " && "), "sh", cmd.Path}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = sh
+	return gate, nil
+}
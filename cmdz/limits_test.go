@@ -0,0 +1,92 @@
+package cmdz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDir(t *testing.T) {
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+
+	e := Cmd("pwd").Dir(dir1)
+	_, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, dir1+"\n", e.StdoutRecord())
+
+	// Sequence dir is a default for its children
+	e1 := Cmd("pwd")
+	e2 := Cmd("pwd").Dir(dir2)
+	s := Serial(e1, e2)
+	s.Dir(dir1)
+	_, err = s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, dir1+"\n", e1.StdoutRecord())
+	assert.Equal(t, dir2+"\n", e2.StdoutRecord())
+}
+
+func TestUmask(t *testing.T) {
+	dir := t.TempDir()
+	e := Sh("umask; touch foo").Umask(0027).Dir(dir)
+	_, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "0027\n", e.StdoutRecord())
+	info, err := os.Stat(filepath.Join(dir, "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestNice(t *testing.T) {
+	e := Cmd("nice").Nice(5)
+	_, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "5\n", e.StdoutRecord())
+	assert.Equal(t, "nice", e.String())
+}
+
+func TestLimits(t *testing.T) {
+	e1 := Sh("ulimit -n; ulimit -t")
+	e2 := Sh("ulimit -n; ulimit -v").Limits(ResourceLimits{OpenFiles: 32, Memory: 512 * 1024 * 1024})
+	p := Parallel(e1, e2)
+	p.Limits(ResourceLimits{OpenFiles: 64, CpuTime: 1500 * time.Millisecond})
+	_, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "64\n2\n", e1.StdoutRecord())
+	assert.Equal(t, "32\n524288\n", e2.StdoutRecord())
+}
+
+func TestLimits_NotFound(t *testing.T) {
+	_, err := Cmd("notExistingBinary").Limits(ResourceLimits{OpenFiles: 32}).BlockRun()
+	assert.Error(t, err)
+}
+
+func TestLimits_Fields(t *testing.T) {
+	cases := []struct {
+		limits   ResourceLimits
+		expected string
+	}{
+		{ResourceLimits{CpuTime: 1500 * time.Millisecond}, `Max cpu time\s+2\s+2\s+seconds`},
+		{ResourceLimits{Memory: 512 * 1024 * 1024}, `Max address space\s+536870912\s+536870912\s+bytes`},
+		{ResourceLimits{OpenFiles: 32}, `Max open files\s+32\s+32\s+files`},
+		{ResourceLimits{Processes: 100}, `Max processes\s+100\s+100\s+processes`},
+	}
+	for _, c := range cases {
+		e := Cmd("cat", "/proc/self/limits").Limits(c.limits)
+		rc, err := e.BlockRun()
+		require.NoError(t, err)
+		assert.Equal(t, 0, rc)
+		assert.Regexp(t, c.expected, e.StdoutRecord())
+	}
+}
+
+func TestLimits_Error(t *testing.T) {
+	// Above the kernel max of open files even for root
+	e := Cmd("true").Limits(ResourceLimits{OpenFiles: 1 << 40})
+	_, err := e.BlockRun()
+	assert.ErrorContains(t, err, "Unable to apply process limits")
+}
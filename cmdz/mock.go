@@ -1,14 +1,20 @@
 package cmdz
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/stretchr/testify/assert"
+	"github.com/mxbossard/utilz/collectionz"
 	"github.com/mxbossard/utilz/ztring"
+	"github.com/stretchr/testify/assert"
 )
 
 var commandMock *CmdMock
@@ -51,6 +57,7 @@ func (m CmdMock) Mock(c *exec.Cmd) int {
 	return mockedRc
 }
 
+// Deprecated: global mock is shared by all tests, use NewMock() and Executer.Mock() instead.
 func StartMock(t *testing.T, callback func(c Vcmd) (rc int, stdout, stderr io.Reader)) {
 	//mockingCommand = callback
 	commandMock = &CmdMock{t, callback}
@@ -85,3 +92,291 @@ func Contains(c Vcmd, parts ...string) bool {
 	}
 	return contains
 }
+
+// Mock is a registry of expected command invocations with canned responses.
+// It is attached to an Executer with Mock() and is propagated to all children of a sequence,
+// so each test can use its own Mock without interfering with parallel tests.
+type Mock struct {
+	mu         sync.Mutex
+	t          *testing.T
+	calls      []*MockedCall
+	unexpected []string
+	ordered    bool
+}
+
+// MockedCall describe an expected invocation and the response to play.
+type MockedCall struct {
+	desc     string
+	argv     func([]string) bool
+	expected []string
+	environ  []string
+	stdin    *string
+
+	rc     int
+	stdout string
+	stderr string
+	delay  time.Duration
+
+	times int
+	count int
+
+	// Match the invocation once its secrets are redacted
+	redacted bool
+}
+
+func NewMock(t *testing.T) *Mock {
+	return &Mock{t: t}
+}
+
+// InOrder require expected calls to be invoked in their declaration order.
+// A call without Times() is never exhausted and so blocks following calls.
+func (m *Mock) InOrder() *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ordered = true
+	return m
+}
+
+// Expect an invocation with exactly this binary and args.
+func (m *Mock) Expect(binaryAndArgs ...string) *MockedCall {
+	expected := append([]string{}, binaryAndArgs...)
+	matcher := func(argv []string) bool {
+		if len(argv) != len(expected) {
+			return false
+		}
+		for i, arg := range argv {
+			if arg != expected[i] {
+				return false
+			}
+		}
+		return true
+	}
+	c := m.ExpectMatching(strings.Join(expected, " "), matcher)
+	c.expected = expected
+	return c
+}
+
+// Expect an invocation whose argv starts with binary and args.
+func (m *Mock) ExpectPrefix(binaryAndArgs ...string) *MockedCall {
+	expected := append([]string{}, binaryAndArgs...)
+	matcher := func(argv []string) bool {
+		if len(argv) < len(expected) {
+			return false
+		}
+		for i, arg := range expected {
+			if arg != argv[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return m.ExpectMatching(strings.Join(expected, " ")+" ...", matcher)
+}
+
+// Expect an invocation whose space joined argv match the regexp.
+func (m *Mock) ExpectRegexp(pattern string) *MockedCall {
+	r := regexp.MustCompile(pattern)
+	matcher := func(argv []string) bool {
+		return r.MatchString(strings.Join(argv, " "))
+	}
+	return m.ExpectMatching("/"+pattern+"/", matcher)
+}
+
+// Expect an invocation whose argv is accepted by the matcher.
+func (m *Mock) ExpectMatching(desc string, matcher func(argv []string) bool) *MockedCall {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := &MockedCall{desc: desc, argv: matcher}
+	m.calls = append(m.calls, c)
+	return c
+}
+
+// Env entries (KEY=VALUE) which must be present in invocation environment.
+func (c *MockedCall) Env(entries ...string) *MockedCall {
+	c.environ = append(c.environ, entries...)
+	return c
+}
+
+// Stdin expected to be fed to the invocation.
+func (c *MockedCall) Stdin(expected string) *MockedCall {
+	c.stdin = &expected
+	return c
+}
+
+func (c *MockedCall) Return(rc int, stdout, stderr string) *MockedCall {
+	c.rc = rc
+	c.stdout = stdout
+	c.stderr = stderr
+	return c
+}
+
+// Simulate an execution duration. A delay exceeding the configured timeout simulate a timeout.
+func (c *MockedCall) Delay(d time.Duration) *MockedCall {
+	c.delay = d
+	return c
+}
+
+// Expect exactly n invocations. By default at least one invocation is expected.
+func (c *MockedCall) Times(n int) *MockedCall {
+	c.times = n
+	return c
+}
+
+func (c *MockedCall) Once() *MockedCall {
+	return c.Times(1)
+}
+
+// An invocation as seen by mocked calls.
+type invocation struct {
+	argv  []string
+	env   []string
+	stdin string
+}
+
+func (c *MockedCall) matches(raw, redacted invocation) bool {
+	inv := raw
+	if c.redacted {
+		inv = redacted
+	}
+	if !c.argv(inv.argv) {
+		return false
+	}
+	for _, entry := range c.environ {
+		if !collectionz.Contains[string](&inv.env, entry) {
+			return false
+		}
+	}
+	if c.stdin != nil && *c.stdin != inv.stdin {
+		return false
+	}
+	return !c.exhausted()
+}
+
+func (c *MockedCall) exhausted() bool {
+	return c.times > 0 && c.count >= c.times
+}
+
+func (c *MockedCall) String() string {
+	return fmt.Sprintf("[%s]", c.desc)
+}
+
+// Play the first expected call matching the command instead of executing it. Secrets are redacted from errors and logs.
+// A delayed call is interrupted when ctx is done.
+func (m *Mock) play(ctx context.Context, cmd *exec.Cmd, timeout time.Duration, secrets []string) (rc int, timedOut bool, err error) {
+	var stdin []byte
+	if cmd.Stdin != nil {
+		stdin, err = io.ReadAll(cmd.Stdin)
+		if err != nil {
+			return -1, false, err
+		}
+	}
+
+	raw := invocation{argv: cmd.Args, env: cmd.Env, stdin: string(stdin)}
+	redacted := invocation{argv: redactAll(cmd.Args, secrets), env: redactAll(cmd.Env, secrets), stdin: redact(string(stdin), secrets)}
+	textCmd := strings.Join(redacted.argv, " ")
+
+	m.mu.Lock()
+	var call *MockedCall
+	var next *MockedCall
+	for _, c := range m.calls {
+		if m.ordered && next == nil && !c.exhausted() {
+			next = c
+		}
+		if m.ordered && c != next {
+			continue
+		}
+		if c.matches(raw, redacted) {
+			call = c
+			call.count++
+			break
+		}
+	}
+	if call == nil && next == nil {
+		// Diff against the first pending call of the same binary
+		for _, c := range m.calls {
+			if !c.exhausted() && len(c.expected) > 0 && len(redacted.argv) > 0 && c.expected[0] == redacted.argv[0] {
+				next = c
+				break
+			}
+		}
+	}
+	if call == nil {
+		m.unexpected = append(m.unexpected, textCmd)
+		m.mu.Unlock()
+		if next != nil && next.expected != nil {
+			return -1, false, fmt.Errorf("unexpected mocked cmd execution: [%s] ! argv diff:\n%s", textCmd, diffArgv(next.expected, redacted.argv))
+		}
+		return -1, false, fmt.Errorf("unexpected mocked cmd execution: [%s]", textCmd)
+	}
+	m.mu.Unlock()
+
+	outSummary := ztring.SummaryRatio(call.stdout, 128, .2)
+	errSummary := ztring.SummaryRatio(call.stderr, 128, .2)
+	log.Printf("Test: %s Mocked cmd execution: [%s] returned RC=%d STDOUT=[%s] STDERR=[%s]", m.t.Name(), textCmd, call.rc, outSummary, errSummary)
+
+	if call.delay > 0 {
+		delay := call.delay
+		timedOut := timeout > 0 && delay > timeout
+		if timedOut {
+			delay = timeout
+		}
+		select {
+		case <-time.After(delay):
+		case <-done(ctx):
+			return -1, false, canceled(ctx)
+		}
+		if timedOut {
+			return -1, true, nil
+		}
+	}
+	if cmd.Stdout != nil {
+		_, err = io.WriteString(cmd.Stdout, call.stdout)
+		if err != nil {
+			return -1, false, err
+		}
+	}
+	if cmd.Stderr != nil {
+		_, err = io.WriteString(cmd.Stderr, call.stderr)
+		if err != nil {
+			return -1, false, err
+		}
+	}
+	return call.rc, false, nil
+}
+
+// Diff expected and actual argv one arg per line.
+func diffArgv(expected, actual []string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if i < len(expected) && i < len(actual) && expected[i] == actual[i] {
+			fmt.Fprintf(&b, "  %s\n", expected[i])
+			continue
+		}
+		if i < len(expected) {
+			fmt.Fprintf(&b, "- %s\n", expected[i])
+		}
+		if i < len(actual) {
+			fmt.Fprintf(&b, "+ %s\n", actual[i])
+		}
+	}
+	return b.String()
+}
+
+// Assert all expected calls were invoked the expected number of times and no unexpected call was made.
+func (m *Mock) AssertExpectations(t *testing.T) bool {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, c := range m.calls {
+		if c.times == 0 && c.count == 0 {
+			ok = assert.Fail(t, "missing mocked cmd invocation", "expected %s to be invoked at least once", c)
+		} else if c.times > 0 && c.count != c.times {
+			ok = assert.Fail(t, "wrong mocked cmd invocation count", "expected %s to be invoked %d time(s) but was invoked %d time(s)", c, c.times, c.count)
+		}
+	}
+	for _, u := range m.unexpected {
+		ok = assert.Fail(t, "unexpected mocked cmd invocation", "[%s]", u)
+	}
+	return ok
+}
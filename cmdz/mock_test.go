@@ -2,8 +2,13 @@ package cmdz
 
 import (
 	//"os/exec"
+	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mxbossard/utilz/errorz"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -31,3 +36,112 @@ func TestCmdzMock(t *testing.T) {
 	assert.Equal(t, "foo\n", c.StdoutRecord())
 
 }
+
+func TestMock_Expectations(t *testing.T) {
+	m := NewMock(t)
+	m.Expect("git", "status").Return(0, "clean\n", "").Once()
+	m.ExpectPrefix("git", "commit").Env("GIT_AUTHOR=me").Return(1, "", "nothing to commit\n")
+
+	c := Cmd("git", "status").Mock(m)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "clean\n", c.StdoutRecord())
+
+	c = Cmd("git", "commit", "-m", "foo").AddEnv("GIT_AUTHOR", "me").Mock(m)
+	rc, err = c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, "nothing to commit\n", c.StderrRecord())
+
+	assert.True(t, m.AssertExpectations(t))
+}
+
+func TestMock_Unexpected(t *testing.T) {
+	m := NewMock(t)
+	m.Expect("git", "status").Once()
+
+	_, err := Cmd("git", "status", "-s").Mock(m).BlockRun()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "git status -s")
+
+	mt := &testing.T{}
+	assert.False(t, m.AssertExpectations(mt))
+	assert.True(t, mt.Failed())
+}
+
+func TestMock_Stdin(t *testing.T) {
+	m := NewMock(t)
+	m.Expect("cat").Stdin("foo").Return(0, "bar", "")
+
+	c := Cmd("cat").SetInput(strings.NewReader("foo")).Mock(m)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "foo", c.StdinRecord())
+	assert.Equal(t, "bar", c.StdoutRecord())
+
+	_, err = Cmd("cat").SetInput(strings.NewReader("baz")).Mock(m).BlockRun()
+	require.Error(t, err)
+}
+
+func TestMock_Delay(t *testing.T) {
+	m := NewMock(t)
+	m.Expect("sleep", "1").Delay(20 * time.Millisecond).Times(2)
+
+	c := Cmd("sleep", "1").Mock(m)
+	rc, err := c.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.GreaterOrEqual(t, c.Duration(), 20*time.Millisecond)
+
+	c.Timeout(5 * time.Millisecond)
+	rc, err = c.BlockRun()
+	require.Error(t, err)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Equal(t, -1, rc)
+
+	m.AssertExpectations(t)
+}
+
+func TestMock_DelayCanceled(t *testing.T) {
+	m := NewMock(t)
+	m.Expect("sleep", "1").Delay(time.Second).Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rc, err := Cmd("sleep", "1").Mock(m).Context(ctx).BlockRun()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, -1, rc)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	m.AssertExpectations(t)
+}
+
+func TestMock_Sequences(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprintf("parallel-%d", i), func(t *testing.T) {
+			t.Parallel()
+			m := NewMock(t)
+			m.Expect("echo", "foo").Return(0, fmt.Sprintf("foo%d", i), "").Once()
+			m.Expect("echo", "bar").Return(0, fmt.Sprintf("bar%d", i), "").Once()
+			m.Expect("false").Return(1, "", "").Times(3)
+
+			foo := Cmd("echo", "foo")
+			bar := Cmd("echo", "bar")
+			f := Cmd("false")
+			s := Serial(Parallel(foo, bar), f).Retries(2, 1).Mock(m)
+			rc, err := s.BlockRun()
+			require.NoError(t, err)
+			assert.Equal(t, 1, rc)
+			assert.Equal(t, fmt.Sprintf("foo%d", i), foo.StdoutRecord())
+			assert.Equal(t, fmt.Sprintf("bar%d", i), bar.StdoutRecord())
+			assert.Equal(t, []int{1, 1, 1}, f.ResultCodes())
+
+			m.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package cmdz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/mxbossard/utilz/anzi"
+	"github.com/mxbossard/utilz/inoutz"
+	"github.com/mxbossard/utilz/promiz"
+)
+
+// Colors cycled to print children labels of a multiplexed parallel sequence.
+var LabelColors = []anzi.Color{anzi.Cyan, anzi.Green, anzi.Yellow, anzi.Blue, anzi.Purple, anzi.Red}
+
+// Write prefixed lines of one child output into a writer shared with other children.
+// Only complete lines are written, so lines of concurrent children never interleave.
+type muxWriter struct {
+	target    io.Writer
+	lock      *sync.Mutex
+	formatter inoutz.Formatter
+	grouped   bool
+	buffer    bytes.Buffer
+}
+
+func (w *muxWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buffer.Write(p)
+	if w.grouped {
+		return len(p), nil
+	}
+	end := bytes.LastIndexByte(w.buffer.Bytes(), '\n')
+	if end < 0 {
+		return len(p), nil
+	}
+	lines := w.buffer.Next(end + 1)
+	_, err := io.WriteString(w.target, w.formatter.Format(string(lines)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Write all remaining buffered bytes. Lock must be held.
+func (w *muxWriter) flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	out := w.buffer.String()
+	w.buffer.Reset()
+	if out[len(out)-1] != '\n' {
+		out += "\n"
+	}
+	_, err := io.WriteString(w.target, w.formatter.Format(out))
+	return err
+}
+
+// A parallel child whose multiplexed outputs are flushed once it ended.
+type muxedExec struct {
+	Executer
+	lock    *sync.Mutex
+	writers []*muxWriter
+}
+
+func (e *muxedExec) flush() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, w := range e.writers {
+		_ = w.flush()
+	}
+}
+
+func (e *muxedExec) BlockRun() (rc int, err error) {
+	return e.blockRunCtx(nil)
+}
+
+func (e *muxedExec) blockRunCtx(ctx context.Context) (rc int, err error) {
+	rc, err = e.Executer.blockRunCtx(ctx)
+	e.flush()
+	return
+}
+
+func (e *muxedExec) AsyncRun() *execPromise {
+	inner := e.Executer.AsyncRun()
+	return promiz.New(func(resolve func(int), reject func(error)) {
+		rc, err := inner.Await(context.Background())
+		e.flush()
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(*rc)
+	})
+}
+
+func (s *parallelSeq) label(idx int) string {
+	if idx < len(s.labels) {
+		return s.labels[idx]
+	}
+	return fmt.Sprintf("#%d", idx+1)
+}
+
+// Replace children outputs by multiplexing writers. Return the wrapped children and a func restoring their outputs.
+func (s *parallelSeq) multiplex(cfg *config) ([]Executer, func()) {
+	lock := &sync.Mutex{}
+	labelWidth := 0
+	for i := range s.execs {
+		labelWidth = max(labelWidth, len(s.label(i)))
+	}
+
+	var wrapped []Executer
+	var restores []func()
+	for i, exec := range s.execs {
+		exec := exec
+		label := anzi.PadRight(s.label(i), labelWidth)
+		if len(s.colors) > 0 {
+			label = string(s.colors[i%len(s.colors)]) + label + string(anzi.Reset)
+		}
+		formatter := inoutz.PrefixFormatter{Prefix: label + " | "}
+		m := &muxedExec{Executer: exec, lock: lock}
+		newWriter := func(target io.Writer) io.Writer {
+			if target == nil {
+				return nil
+			}
+			w := &muxWriter{target: target, lock: lock, formatter: formatter, grouped: s.groupedOutputs}
+			m.writers = append(m.writers, w)
+			return w
+		}
+
+		stdout, stderr := exec.Stdout(), exec.Stderr()
+		outTarget, errTarget := stdout, stderr
+		if outTarget == nil {
+			outTarget = cfg.stdout
+		}
+		if errTarget == nil {
+			errTarget = cfg.stderr
+		}
+		exec.SetOutputs(newWriter(outTarget), newWriter(errTarget))
+		restores = append(restores, func() { exec.SetOutputs(stdout, stderr) })
+		wrapped = append(wrapped, m)
+	}
+	return wrapped, func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
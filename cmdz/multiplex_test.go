@@ -0,0 +1,69 @@
+package cmdz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mxbossard/utilz/anzi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnLine(t *testing.T) {
+	var outLines, errLines []string
+	e := Sh("echo foo; >&2 echo bar; printf 'baz\nqux'").
+		OnStdoutLine(func(line string) { outLines = append(outLines, line) }).
+		OnStderrLine(func(line string) { errLines = append(errLines, line) })
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []string{"foo\n", "baz\n", "qux"}, outLines)
+	assert.Equal(t, []string{"bar\n"}, errLines)
+	assert.Equal(t, "foo\nbaz\nqux", e.StdoutRecord())
+}
+
+func TestParallel_Multiplex(t *testing.T) {
+	stdout := &strings.Builder{}
+	stderr := &strings.Builder{}
+	e1 := Sh("for i in 1 2 3; do echo a$i; sleep 0.01; done; >&2 echo aerr")
+	e2 := Sh("for i in 1 2 3; do printf b; sleep 0.005; echo $i; done")
+	p := Parallel(e1, e2).Multiplex(false).Labels("first", "2nd")
+	p.SetOutputs(stdout, stderr)
+	rc, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+
+	lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	assert.Len(t, lines, 6)
+	for _, line := range lines {
+		assert.Regexp(t, `^(first \| a[123]|2nd   \| b[123])$`, line)
+	}
+	assert.Equal(t, "first | aerr\n", stderr.String())
+
+	// Records are not prefixed
+	assert.Equal(t, "a1\na2\na3\n", e1.StdoutRecord())
+
+	// Children outputs are restored
+	assert.Equal(t, stdout, e1.Stdout())
+	assert.Equal(t, stderr, e2.Stderr())
+}
+
+func TestParallel_MultiplexGrouped(t *testing.T) {
+	stdout := &strings.Builder{}
+	e1 := Sh("echo a1; sleep 0.05; echo a2")
+	e2 := Sh("echo b1; sleep 0.02; printf b2")
+	p := Parallel(e1, e2).Multiplex(true)
+	p.SetStdout(stdout)
+	_, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "#2 | b1\n#2 | b2\n#1 | a1\n#1 | a2\n", stdout.String())
+}
+
+func TestParallel_MultiplexColors(t *testing.T) {
+	stdout := &strings.Builder{}
+	p := Parallel(Cmd("echo", "foo")).Multiplex(false).Labels("foo").Colors()
+	p.SetStdout(stdout)
+	_, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, string(anzi.Cyan)+"foo"+string(anzi.Reset)+" | foo\n", stdout.String())
+}
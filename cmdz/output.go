@@ -1,8 +1,13 @@
 package cmdz
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"syscall"
 	"time"
+
+	"github.com/mxbossard/utilz/printz"
 )
 
 type (
@@ -47,16 +52,116 @@ func (e *basicOutput) CombinedOutputs() Outputer {
 	return e
 }
 
+func (e *basicOutput) Mock(m *Mock) Outputer {
+	e.Executer = e.Executer.Mock(m)
+	return e
+}
+
+func (e *basicOutput) Record(r *Recording) Outputer {
+	e.Executer = e.Executer.Record(r)
+	return e
+}
+
+func (e *basicOutput) InProcess(fn ProcessFunc) Outputer {
+	e.Executer = e.Executer.InProcess(fn)
+	return e
+}
+
 func (e *basicOutput) Retries(count, delayInMs int) Outputer {
 	e.Executer = e.Executer.Retries(count, delayInMs)
 	return e
 }
 
+func (e *basicOutput) Backoff(factor float64, maxDelay time.Duration, jitter float64) Outputer {
+	e.Executer = e.Executer.Backoff(factor, maxDelay, jitter)
+	return e
+}
+
+func (e *basicOutput) RetryTimeout(total time.Duration) Outputer {
+	e.Executer = e.Executer.RetryTimeout(total)
+	return e
+}
+
+func (e *basicOutput) RetryIf(predicate RetryPredicate) Outputer {
+	e.Executer = e.Executer.RetryIf(predicate)
+	return e
+}
+
 func (e *basicOutput) Timeout(duration time.Duration) Outputer {
 	e.Executer = e.Executer.Timeout(duration)
 	return e
 }
 
+func (e *basicOutput) Termination(signal syscall.Signal, grace time.Duration) Outputer {
+	e.Executer = e.Executer.Termination(signal, grace)
+	return e
+}
+
+func (e *basicOutput) Context(ctx context.Context) Outputer {
+	e.Executer = e.Executer.Context(ctx)
+	return e
+}
+
+func (e *basicOutput) Dir(path string) Outputer {
+	e.Executer = e.Executer.Dir(path)
+	return e
+}
+
+func (e *basicOutput) Nice(n int) Outputer {
+	e.Executer = e.Executer.Nice(n)
+	return e
+}
+
+func (e *basicOutput) Umask(mask os.FileMode) Outputer {
+	e.Executer = e.Executer.Umask(mask)
+	return e
+}
+
+func (e *basicOutput) Limits(limits ResourceLimits) Outputer {
+	e.Executer = e.Executer.Limits(limits)
+	return e
+}
+
+func (e *basicOutput) InheritEnv(allowed ...string) Outputer {
+	e.Executer = e.Executer.InheritEnv(allowed...)
+	return e
+}
+
+func (e *basicOutput) CleanEnv() Outputer {
+	e.Executer = e.Executer.CleanEnv()
+	return e
+}
+
+func (e *basicOutput) Secrets(values ...string) Outputer {
+	e.Executer = e.Executer.Secrets(values...)
+	return e
+}
+
+func (e *basicOutput) Hooks(hooks ...Hooks) Outputer {
+	e.Executer = e.Executer.Hooks(hooks...)
+	return e
+}
+
+func (e *basicOutput) DryRun(printer printz.Printer) Outputer {
+	e.Executer = e.Executer.DryRun(printer)
+	return e
+}
+
+func (e *basicOutput) DryRunRc(rc int) Outputer {
+	e.Executer = e.Executer.DryRunRc(rc)
+	return e
+}
+
+func (e *basicOutput) RecordLimit(maxBytes int) Outputer {
+	e.Executer = e.Executer.RecordLimit(maxBytes)
+	return e
+}
+
+func (e *basicOutput) SpillRecords() Outputer {
+	e.Executer = e.Executer.SpillRecords()
+	return e
+}
+
 func (e *basicOutput) AddEnv(key, value string) Outputer {
 	e.Executer = e.Executer.AddEnv(key, value)
 	return e
@@ -1,8 +1,10 @@
 package cmdz
 
 import (
-	"bytes"
+	"context"
 	"fmt"
+	"os"
+	"sync"
 )
 
 type (
@@ -10,6 +12,7 @@ type (
 		Executer
 		feeder   Executer
 		pipeFail bool
+		status   int
 	}
 )
 
@@ -23,7 +26,17 @@ func (e *basicPipe) PipeFail(c Executer) Executer {
 	return e.Pipe(c)
 }
 
-func (e *basicPipe) BlockRun() (int, error) {
+func (e *basicPipe) BlockRun() (rc int, err error) {
+	return e.blockRunCtx(nil)
+}
+
+func (e *basicPipe) blockRunCtx(ctx context.Context) (rc int, err error) {
+	rc, err = e.blockRun(ctx)
+	e.status = rc
+	return
+}
+
+func (e *basicPipe) blockRun(ctx context.Context) (int, error) {
 	if e.Executer == nil {
 		return -1, fmt.Errorf("basicPipe don't have a sink !")
 	}
@@ -34,27 +47,48 @@ func (e *basicPipe) BlockRun() (int, error) {
 	f := e.feeder
 	//f.init()
 	originalStdout := f.getConfig().stdout
-	originalStdin := f.getConfig().stdin
-	b := bytes.Buffer{}
+	originalStdin := e.Executer.getConfig().stdin
+	r, w, err := os.Pipe()
+	if err != nil {
+		return -1, err
+	}
+	defer r.Close()
+	defer w.Close()
 
 	// Replace configured stdin / stdout temporarilly
-	f.SetStdout(&b)
-	e.SetInput(&b)
+	f.SetStdout(w)
+	e.SetInput(r)
 	defer f.SetStdout(originalStdout)
 	defer e.SetInput(originalStdin)
 
-	frc, ferr := e.feeder.BlockRun()
+	// Run feeder and sink concurrently
+	var frc int
+	var ferr error
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		frc, ferr = f.blockRunCtx(ctx)
+		// Send EOF to the sink
+		w.Close()
+	}()
+	rc, err := e.Executer.blockRunCtx(ctx)
+	// Send EPIPE to the feeder
+	r.Close()
+	wg.Wait()
+
 	if _, ok := ferr.(failure); ferr != nil && !ok {
 		// If feeder error is not a failure{} return it immediately
 		return frc, ferr
 	}
 
-	if e.pipeFail && frc > 0 {
-		// if pipefail enabled and rc > 0 fail shortly
-		return frc, ferr
+	if e.pipeFail && rc == 0 && err == nil && frc != 0 {
+		// if pipefail enabled and feeder failed
+		if f, ok := ferr.(failure); ok {
+			return f.Rc, nil
+		}
+		return frc, nil
 	}
 
-	rc, err := e.Executer.BlockRun()
-
 	return rc, err
 }
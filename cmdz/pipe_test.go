@@ -3,6 +3,7 @@ package cmdz
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -88,5 +89,62 @@ func TestBasicPipe_PipeFail(t *testing.T) {
 	rc, err = p.PipeFail(right).BlockRun()
 	require.NoError(t, err)
 	assert.Equal(t, 1, rc)
-	assert.Equal(t, "", p.StdoutRecord())
+	// Feeder and sink run concurrently
+	assert.Equal(t, "foo\n", p.StdoutRecord())
+}
+
+func TestPipeline_Streaming(t *testing.T) {
+	// Infinite producer must be stopped by SIGPIPE once consumer exits
+	yes := Cmd("yes")
+	head := Cmd("head", "-n", "3")
+	p := yes.Pipe(head)
+	start := time.Now()
+	rc, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, "y\ny\ny\n", head.StdoutRecord())
+	assert.Equal(t, []int{141, 0}, p.PipeStatus())
+
+	// Stages are started concurrently
+	slow := Sh("echo foo ; sleep 0.2 ; echo bar")
+	stdout := strings.Builder{}
+	first := Cmd("head", "-n", "1").SetStdout(&stdout)
+	p = slow.Pipe(first.(*cmdz))
+	start = time.Now()
+	rc, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "foo\n", stdout.String())
+	assert.Equal(t, "foo\nbar\n", slow.StdoutRecord())
+}
+
+func TestPipeline_PipeFail(t *testing.T) {
+	newStages := func() (*cmdz, *cmdz, *cmdz) {
+		return Sh("echo foo ; exit 2"), Sh("cat ; exit 3"), Cmd("sed", "-e", "s/o/a/")
+	}
+
+	a, b, c := newStages()
+	p := a.Pipe(b).Pipe(c)
+	rc, err := p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []int{2, 3, 0}, p.PipeStatus())
+	assert.Equal(t, "foo\n", a.StdoutRecord())
+	assert.Equal(t, "foo\n", b.StdinRecord())
+	assert.Equal(t, "foo\n", b.StdoutRecord())
+	assert.Equal(t, "fao\n", c.StdoutRecord())
+
+	// Rightmost failure of a pipefail stage
+	a, b, c = newStages()
+	p = a.PipeFail(b).PipeFail(c)
+	rc, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+
+	a, b, c = newStages()
+	p = a.PipeFail(b).Pipe(c)
+	rc, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 2, rc)
 }
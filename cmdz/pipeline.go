@@ -0,0 +1,125 @@
+package cmdz
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// Run the whole pipeline ending with e. All stages are started concurrently, in order,
+// connected by OS pipes so outputs are streamed from one stage to the next.
+// Stages are not retried because their streamed input cannot be replayed.
+func (e *cmdz) blockRunPipeline(runCtx context.Context) (rc int, err error) {
+	if cfg := mergeConfigs(&e.config, e.fallbackConfig); cfg.dryRun != nil {
+		return e.dryRunPipeline(cfg)
+	}
+	var stages []*cmdz
+	for s := e; s != nil; s = s.feeder {
+		stages = append([]*cmdz{s}, stages...)
+	}
+	count := len(stages)
+
+	// Connect each stage stdout to next stage stdin
+	readers := make([]*os.File, count)
+	writers := make([]*os.File, count)
+	defer func() {
+		for i := range stages {
+			closeFile(readers[i])
+			closeFile(writers[i])
+		}
+	}()
+	for i := 0; i < count-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return -1, err
+		}
+		readers[i+1] = r
+		writers[i] = w
+	}
+
+	ctx := withRunCtx(mergeConfigs(&e.config, e.fallbackConfig), runCtx).ctx
+
+	// Replace configured stdin / stdout temporarilly
+	configs := make([]*config, count)
+	for i, s := range stages {
+		if writers[i] != nil {
+			defer func(s *cmdz, original io.Writer) { s.config.stdout = original }(s, s.config.stdout)
+			s.pipedOutput = true
+			s.config.stdout = writers[i]
+		}
+		if readers[i] != nil {
+			defer func(s *cmdz, original io.Reader) { s.config.stdin = original }(s, s.config.stdin)
+			s.pipedInput = true
+			s.config.stdin = readers[i]
+		}
+		cfg := *s.prepare()
+		cfg.retries = 0
+		if cfg.ctx == nil {
+			// Only the last stage inherits config from sequences
+			cfg.ctx = ctx
+		}
+		configs[i] = &cfg
+	}
+
+	rcs := make([]int, count)
+	errs := make([]error, count)
+	wg := sync.WaitGroup{}
+	for i, s := range stages {
+		started := make(chan error, 1)
+		once := sync.Once{}
+		notify := func(err error) {
+			once.Do(func() { started <- err })
+		}
+		wg.Add(1)
+		go func(i int, s *cmdz) {
+			defer wg.Done()
+			rcs[i], errs[i] = s.blockRunAttempts(configs[i], notify)
+			notify(errs[i])
+			// Send EOF to next stage and EPIPE to previous stage
+			closeFile(writers[i])
+			closeFile(readers[i])
+		}(i, s)
+		if startErr := <-started; startErr != nil {
+			// Do not start following stages
+			break
+		}
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if f, ok := err.(failure); ok {
+			rcs[i] = f.Rc
+		} else if err != nil {
+			return -1, err
+		}
+	}
+	e.pipeStatus = rcs
+
+	rc = pipelineResult(stages, rcs)
+	if e.errorOnFailure && rc > 0 {
+		err = failure{rc, e}
+		rc = -1
+	}
+	return
+}
+
+// Pipeline result is the last stage result, or with pipefail the rightmost non zero result of a pipefail stage.
+func pipelineResult(stages []*cmdz, rcs []int) int {
+	last := len(rcs) - 1
+	if rcs[last] != 0 {
+		return rcs[last]
+	}
+	for i := last - 1; i >= 0; i-- {
+		if stages[i].pipeFail && rcs[i] != 0 {
+			return rcs[i]
+		}
+	}
+	return 0
+}
+
+func closeFile(f *os.File) {
+	if f != nil {
+		_ = f.Close()
+	}
+}
@@ -0,0 +1,90 @@
+package cmdz
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTermSignal = syscall.SIGTERM
+	defaultTermGrace  = 2 * time.Second
+)
+
+// Start the process in its own process group, so signals can reach all its descendants.
+func setupProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// groupTerminator terminate the process group of a started command.
+type groupTerminator struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	signal syscall.Signal
+	grace  time.Duration
+	kill   *time.Timer
+	// Leader was reaped, its pid may be reused
+	released bool
+}
+
+func newGroupTerminator(cmd *exec.Cmd, signal syscall.Signal, grace time.Duration) *groupTerminator {
+	if signal == 0 {
+		signal = defaultTermSignal
+	}
+	if grace == 0 {
+		grace = defaultTermGrace
+	}
+	return &groupTerminator{cmd: cmd, signal: signal, grace: grace}
+}
+
+// Send signal to the whole process group, then SIGKILL the group if some members are still alive after grace period.
+func (t *groupTerminator) terminate() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cmd.Process == nil || t.released {
+		return nil
+	}
+	pgid := t.cmd.Process.Pid
+	err := syscall.Kill(-pgid, t.signal)
+	if errors.Is(err, syscall.ESRCH) {
+		// Group already gone
+		return nil
+	}
+	if err != nil || t.signal == syscall.SIGKILL {
+		return err
+	}
+	if t.kill == nil {
+		t.kill = time.AfterFunc(t.grace, func() {
+			_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+	}
+	return nil
+}
+
+// Release the group once its leader is reaped: the pending SIGKILL is cancelled if the group is gone,
+// because its pgid could then be reused. A pgid cannot be reused while the group still has members.
+func (t *groupTerminator) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.released = true
+	if t.kill == nil {
+		return
+	}
+	if err := syscall.Kill(-t.cmd.Process.Pid, 0); errors.Is(err, syscall.ESRCH) {
+		t.kill.Stop()
+	}
+}
+
+// Exit code of a process. A process killed by a signal exit with 128 + signal number like in shells.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
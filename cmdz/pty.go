@@ -0,0 +1,293 @@
+package cmdz
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultPtyRows = 24
+	defaultPtyCols = 80
+	ptyEOF         = 0x04
+)
+
+// PtyOptions configure the pseudo-terminal a command is executed in.
+type PtyOptions struct {
+	// Initial window size. Default to the parent terminal size if any, or 24x80.
+	Rows, Cols uint16
+	// Terminal echo input characters back into output.
+	Echo bool
+	// Relay the parent terminal input in raw mode instead of the configured stdin.
+	Relay bool
+}
+
+// A running pseudo-terminal session. The process stdio are replaced by the terminal slave side,
+// and the master side is copied from original stdin and into original stdout.
+type ptySession struct {
+	opts   PtyOptions
+	master *os.File
+	slave  *os.File
+	stdin  io.Reader
+	stdout io.Writer
+
+	output      sync.WaitGroup
+	winch       chan os.Signal
+	parentState *unix.Termios
+	relay       *inputRelay
+}
+
+func isTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), unix.TCGETS)
+	return err == nil
+}
+
+// Call f with the file descriptor without switching the file to blocking mode like Fd() does,
+// so pending reads are still interrupted on Close.
+func controlFile(f *os.File, control func(fd int) error) error {
+	raw, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var controlErr error
+	err = raw.Control(func(fd uintptr) {
+		controlErr = control(int(fd))
+	})
+	if err != nil {
+		return err
+	}
+	return controlErr
+}
+
+func openPtyPair() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var n int
+	err = controlFile(master, func(fd int) (err error) {
+		if err = unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+			return
+		}
+		n, err = unix.IoctlGetInt(fd, unix.TIOCGPTN)
+		return
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return
+}
+
+// Open a pseudo-terminal and attach it to cmd which will be started in a new session controlled by the terminal.
+func openPty(cmd *exec.Cmd, opts PtyOptions) (*ptySession, error) {
+	master, slave, err := openPtyPair()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open a pseudo-terminal ! Caused by: %w", err)
+	}
+	s := &ptySession{opts: opts, master: master, slave: slave, stdin: cmd.Stdin, stdout: cmd.Stdout}
+
+	size := &unix.Winsize{Row: defaultPtyRows, Col: defaultPtyCols}
+	if parentSize, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ); err == nil {
+		size = parentSize
+	}
+	if opts.Rows > 0 && opts.Cols > 0 {
+		size = &unix.Winsize{Row: opts.Rows, Col: opts.Cols}
+	}
+	if err = unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, size); err != nil {
+		s.close()
+		return nil, fmt.Errorf("Unable to set pseudo-terminal size ! Caused by: %w", err)
+	}
+	if !opts.Echo {
+		termios, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+		if err == nil {
+			termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ECHOCTL
+			err = unix.IoctlSetTermios(int(slave.Fd()), unix.TCSETS, termios)
+		}
+		if err != nil {
+			s.close()
+			return nil, fmt.Errorf("Unable to disable pseudo-terminal echo ! Caused by: %w", err)
+		}
+	}
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	// A session leader cannot change its process group, but its group id is its pid anyway.
+	attr := syscall.SysProcAttr{}
+	if cmd.SysProcAttr != nil {
+		attr = *cmd.SysProcAttr
+	}
+	attr.Setpgid = false
+	attr.Setsid = true
+	attr.Setctty = true
+	attr.Ctty = 0
+	cmd.SysProcAttr = &attr
+	return s, nil
+}
+
+// Start relaying the terminal once the process started.
+func (s *ptySession) start() {
+	// Only the child must keep the slave side open, so reading the master side ends when the child exits.
+	_ = s.slave.Close()
+	s.slave = nil
+
+	s.output.Add(1)
+	go func() {
+		defer s.output.Done()
+		// Reading ends with EIO once the slave side hung up
+		_, _ = io.Copy(s.stdout, s.master)
+	}()
+
+	if s.opts.Relay && isTerminal(os.Stdin) {
+		if state, err := makeRaw(int(os.Stdin.Fd())); err == nil {
+			s.parentState = state
+		}
+		if relay, err := startInputRelay(s.master, os.Stdin); err == nil {
+			s.relay = relay
+		}
+	} else if s.stdin != nil {
+		go func() {
+			w := &lastByteWriter{Writer: s.master}
+			_, err := io.Copy(w, s.stdin)
+			if err != nil {
+				return
+			}
+			// Signal end of input. A first EOF char only flushes an unterminated line.
+			if w.last != 0 && w.last != '\n' {
+				_, _ = s.master.Write([]byte{ptyEOF})
+			}
+			_, _ = s.master.Write([]byte{ptyEOF})
+		}()
+	}
+
+	if isTerminal(os.Stdin) && (s.opts.Rows == 0 || s.opts.Cols == 0) {
+		s.winch = make(chan os.Signal, 1)
+		signal.Notify(s.winch, syscall.SIGWINCH)
+		go func() {
+			for range s.winch {
+				if size, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ); err == nil {
+					_ = controlFile(s.master, func(fd int) error {
+						return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, size)
+					})
+				}
+			}
+		}()
+	}
+}
+
+// Wait all output was relayed, then release the terminal.
+func (s *ptySession) wait() {
+	s.output.Wait()
+	s.close()
+}
+
+func (s *ptySession) close() {
+	if s.relay != nil {
+		s.relay.stop()
+		s.relay = nil
+	}
+	if s.winch != nil {
+		signal.Stop(s.winch)
+		close(s.winch)
+		s.winch = nil
+	}
+	if s.parentState != nil {
+		_ = unix.IoctlSetTermios(int(os.Stdin.Fd()), unix.TCSETS, s.parentState)
+		s.parentState = nil
+	}
+	closeFile(s.slave)
+	closeFile(s.master)
+}
+
+// inputRelay copy a file into a writer until stopped. Once stopped nothing more is read from the file,
+// so the parent stdin is left intact for its next reader.
+type inputRelay struct {
+	wakeReader *os.File
+	wakeWriter *os.File
+	done       chan struct{}
+}
+
+func startInputRelay(dst io.Writer, src *os.File) (*inputRelay, error) {
+	wakeReader, wakeWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	r := &inputRelay{wakeReader: wakeReader, wakeWriter: wakeWriter, done: make(chan struct{})}
+	go r.copy(dst, int(src.Fd()), int(wakeReader.Fd()))
+	return r, nil
+}
+
+// Poll src and the wake pipe, so a stop interrupts a relay blocked waiting for input.
+func (r *inputRelay) copy(dst io.Writer, src, wake int) {
+	defer close(r.done)
+	buffer := make([]byte, 4096)
+	for {
+		fds := []unix.PollFd{{Fd: int32(src), Events: unix.POLLIN}, {Fd: int32(wake), Events: unix.POLLIN}}
+		if _, err := unix.Poll(fds, -1); err == unix.EINTR {
+			continue
+		} else if err != nil || fds[1].Revents != 0 || fds[0].Revents&(unix.POLLIN|unix.POLLHUP) == 0 {
+			return
+		}
+		n, err := unix.Read(src, buffer)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		} else if err != nil || n == 0 {
+			return
+		}
+		if _, err = dst.Write(buffer[:n]); err != nil {
+			return
+		}
+	}
+}
+
+// Stop relaying and wait for the relay to end.
+func (r *inputRelay) stop() {
+	_ = r.wakeWriter.Close()
+	<-r.done
+	_ = r.wakeReader.Close()
+}
+
+// Put terminal fd in raw mode like cfmakeraw(3). Return the previous state to restore.
+func makeRaw(fd int) (*unix.Termios, error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	previous := *termios
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB
+	termios.Cflag |= unix.CS8
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+type lastByteWriter struct {
+	io.Writer
+	last byte
+}
+
+func (w *lastByteWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	if n > 0 {
+		w.last = p[n-1]
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package cmdz
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/inoutz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPty_Terminal(t *testing.T) {
+	e := Sh("[ -t 0 ] && [ -t 1 ] && [ -t 2 ] && echo tty; stty size; >&2 echo err").Pty(PtyOptions{Rows: 30, Cols: 100})
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "tty\r\n30 100\r\nerr\r\n", e.StdoutRecord())
+	assert.Empty(t, e.StderrRecord())
+
+	e = Sh("[ -t 1 ] || echo notty")
+	_, err = e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "notty\n", e.StdoutRecord())
+}
+
+func TestPty_Input(t *testing.T) {
+	e := Sh("read -r a; echo got:$a; cat").Pty(PtyOptions{})
+	e.SetInput(strings.NewReader("foo\nbar"))
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "got:foo\r\nbar", e.StdoutRecord())
+	assert.Equal(t, "foo\nbar", e.StdinRecord())
+
+	e = Sh("read -r a; echo got:$a").Pty(PtyOptions{Echo: true})
+	e.SetInput(strings.NewReader("foo\n"))
+	_, err = e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\r\ngot:foo\r\n", e.StdoutRecord())
+}
+
+func TestPty_ProcessOut(t *testing.T) {
+	stdout := &strings.Builder{}
+	e := Sh("echo foo").Pty(PtyOptions{})
+	e.ProcessOut(inoutz.StringLineProcesser(func(in string) (string, error) {
+		return "PREFIX" + in, nil
+	}))
+	e.SetStdout(stdout)
+	_, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "PREFIXfoo\r\n", stdout.String())
+	assert.Equal(t, "PREFIXfoo\r\n", e.StdoutRecord())
+}
+
+func TestPty_Timeout(t *testing.T) {
+	e := Sh("sleep 2").Pty(PtyOptions{}).Timeout(100 * time.Millisecond)
+	start := time.Now()
+	_, err := e.BlockRun()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestPty_InputRelayStop(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	defer reader.Close()
+	defer writer.Close()
+
+	relayedReader, relayed, err := os.Pipe()
+	require.NoError(t, err)
+	defer relayedReader.Close()
+	defer relayed.Close()
+
+	relay, err := startInputRelay(relayed, reader)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("foo"))
+	require.NoError(t, err)
+	buffer := make([]byte, 8)
+	n, err := relayedReader.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(buffer[:n]))
+
+	// Once stopped, input is left to the next reader
+	relay.stop()
+	_, err = writer.Write([]byte("bar"))
+	require.NoError(t, err)
+	n, err = reader.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(buffer[:n]))
+}
@@ -0,0 +1,268 @@
+package cmdz
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mxbossard/utilz/collectionz"
+	"github.com/mxbossard/utilz/ztring"
+)
+
+// Max length of stdout and stderr kept in execution reports.
+var ReportOutputMaxLength = 1024
+
+const (
+	CmdKind      = "cmd"
+	PipeKind     = "pipe"
+	SerialKind   = "serial"
+	AndKind      = "and"
+	OrKind       = "or"
+	ParallelKind = "parallel"
+	GraphKind    = "graph"
+)
+
+// AttemptReport describe one execution attempt of a command.
+type AttemptReport struct {
+	Rc       int           `json:"rc"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// ExecutionReport is the structured execution tree of an Executer.
+type ExecutionReport struct {
+	Kind     string            `json:"kind"`
+	Name     string            `json:"name"`
+	Argv     []string          `json:"argv,omitempty"`
+	Env      []string          `json:"env,omitempty"`
+	Rc       int               `json:"rc"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+	Attempts []AttemptReport   `json:"attempts,omitempty"`
+	Cached   bool              `json:"cached,omitempty"`
+	Stdout   string            `json:"stdout,omitempty"`
+	Stderr   string            `json:"stderr,omitempty"`
+	Children []ExecutionReport `json:"children,omitempty"`
+}
+
+// Report build the execution tree of an Executer after it was run.
+func Report(e Executer) ExecutionReport {
+	return e.report()
+}
+
+func (r ExecutionReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Return environment entries which are not inherited from current process environment.
+func envDelta(env []string) (delta []string) {
+	environ := os.Environ()
+	for _, entry := range env {
+		if !collectionz.Contains[string](&environ, entry) {
+			delta = append(delta, entry)
+		}
+	}
+	return
+}
+
+func truncateOutput(s string) string {
+	return ztring.ElideRatio(s, ReportOutputMaxLength, 0.2)
+}
+
+func (e *cmdz) report() ExecutionReport {
+	if e.feeder != nil {
+		var stages []*cmdz
+		for s := e; s != nil; s = s.feeder {
+			stages = append([]*cmdz{s}, stages...)
+		}
+		var children []ExecutionReport
+		for _, s := range stages {
+			children = append(children, s.stageReport())
+		}
+		r := parentReport(PipeKind, e.String(), children)
+		if len(e.pipeStatus) > 0 {
+			r.Rc = pipelineResult(stages, e.pipeStatus)
+		}
+		return r
+	}
+	return e.stageReport()
+}
+
+// Report of this command only, ignoring its feeders.
+func (e *cmdz) stageReport() ExecutionReport {
+	r := ExecutionReport{
+		Kind:   CmdKind,
+		Name:   e.String(),
+		Argv:   redactAll(append([]string{e.binary}, e.args...), e.secrets()),
+		Env:    redactAll(envDelta(e.environ), e.secrets()),
+		Stdout: truncateOutput(e.StdoutRecord()),
+		Stderr: truncateOutput(e.StderrRecord()),
+	}
+	for i, rc := range e.exitCodes {
+		a := AttemptReport{Rc: rc}
+		if i < len(e.startTimes) {
+			a.Start = e.startTimes[i]
+		}
+		if i < len(e.durations) {
+			a.Duration = e.durations[i]
+		}
+		r.Attempts = append(r.Attempts, a)
+	}
+	if len(r.Attempts) > 0 {
+		first := r.Attempts[0]
+		last := r.Attempts[len(r.Attempts)-1]
+		r.Rc = last.Rc
+		r.Start = first.Start
+		r.Duration = last.Start.Add(last.Duration).Sub(first.Start)
+	}
+	return r
+}
+
+// Report of a sequence spanning from its first child start to its last child end.
+func parentReport(kind, name string, children []ExecutionReport) ExecutionReport {
+	r := ExecutionReport{Kind: kind, Name: name, Children: children}
+	var end time.Time
+	for _, c := range children {
+		if c.Start.IsZero() {
+			continue
+		}
+		if r.Start.IsZero() || c.Start.Before(r.Start) {
+			r.Start = c.Start
+		}
+		if cEnd := c.Start.Add(c.Duration); cEnd.After(end) {
+			end = cEnd
+		}
+	}
+	if !r.Start.IsZero() {
+		r.Duration = end.Sub(r.Start)
+	}
+	return r
+}
+
+func (s *seq) reportSeq(kind, name string) ExecutionReport {
+	var children []ExecutionReport
+	for _, e := range s.execs {
+		children = append(children, e.report())
+	}
+	r := parentReport(kind, name, children)
+	r.Rc = s.status
+	return r
+}
+
+func (s *serialSeq) report() ExecutionReport {
+	return s.reportSeq(SerialKind, s.String())
+}
+
+func (s *andSeq) report() ExecutionReport {
+	return s.reportSeq(AndKind, s.String())
+}
+
+func (s *orSeq) report() ExecutionReport {
+	return s.reportSeq(OrKind, s.String())
+}
+
+func (s *parallelSeq) report() ExecutionReport {
+	return s.reportSeq(ParallelKind, s.String())
+}
+
+func (s *graphSeq) report() ExecutionReport {
+	return s.reportSeq(GraphKind, s.String())
+}
+
+func (e *basicPipe) report() ExecutionReport {
+	var children []ExecutionReport
+	if e.feeder != nil {
+		children = append(children, e.feeder.report())
+	}
+	if e.Executer != nil {
+		children = append(children, e.Executer.report())
+	}
+	r := parentReport(PipeKind, e.String(), children)
+	r.Rc = e.status
+	return r
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// Collect all commands of the tree as test cases. Class name is the path of kinds leading to the command.
+func (r ExecutionReport) junitCases(path []string) (cases []junitTestCase) {
+	if r.Kind != CmdKind {
+		for _, c := range r.Children {
+			cases = append(cases, c.junitCases(append(path, r.Kind))...)
+		}
+		return
+	}
+	tc := junitTestCase{
+		Name:      r.Name,
+		Classname: strings.Join(append(path, r.Kind), "."),
+		Time:      junitSeconds(r.Duration),
+		SystemOut: r.Stdout,
+		SystemErr: r.Stderr,
+	}
+	if len(r.Attempts) == 0 && !r.Cached {
+		tc.Skipped = &struct{}{}
+	} else if r.Rc != 0 {
+		tc.Failure = &junitFailure{
+			Message: fmt.Sprintf("Failing with ResultCode: %d after %d attempt(s)", r.Rc, len(r.Attempts)),
+			Content: r.Stderr,
+		}
+	}
+	return []junitTestCase{tc}
+}
+
+// JUnit serialize the report as a JUnit XML test suite where each command is a test case.
+func (r ExecutionReport) JUnit(suiteName string) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:  suiteName,
+		Time:  junitSeconds(r.Duration),
+		Cases: r.junitCases(nil),
+	}
+	if !r.Start.IsZero() {
+		suite.Timestamp = r.Start.Format(time.RFC3339)
+	}
+	suite.Tests = len(suite.Cases)
+	for _, c := range suite.Cases {
+		if c.Failure != nil {
+			suite.Failures++
+		}
+	}
+	out, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,85 @@
+package cmdz
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport_Cmd(t *testing.T) {
+	c := Sh(">&2 echo bar ; echo $FOO ; exit 1").AddEnv("FOO", "foo").Retries(1, 1)
+	_, err := c.BlockRun()
+	require.NoError(t, err)
+
+	r := Report(c)
+	assert.Equal(t, CmdKind, r.Kind)
+	assert.Equal(t, []string{"sh", "-c", ">&2 echo bar ; echo $FOO ; exit 1"}, r.Argv)
+	assert.Equal(t, []string{"FOO=foo"}, r.Env)
+	assert.Equal(t, 1, r.Rc)
+	require.Len(t, r.Attempts, 2)
+	assert.Equal(t, 1, r.Attempts[1].Rc)
+	assert.Equal(t, c.StartTimes()[0], r.Start)
+	assert.GreaterOrEqual(t, r.Duration, r.Attempts[0].Duration+r.Attempts[1].Duration)
+	assert.Equal(t, "foo\nfoo\n", r.Stdout)
+	assert.Equal(t, "bar\nbar\n", r.Stderr)
+}
+
+func TestReport_Tree(t *testing.T) {
+	echo := Cmd("echo", "foo")
+	f := Cmd("false")
+	sed := Cmd("sed", "-e", "s/o/a/")
+	pipe := Cmd("echo", "bar").Pipe(sed)
+	notRun := Cmd("echo", "baz")
+	s := Serial(Parallel(echo, f), Or(pipe, notRun))
+	rc, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+
+	r := Report(s)
+	assert.Equal(t, SerialKind, r.Kind)
+	require.Len(t, r.Children, 2)
+	assert.Equal(t, ParallelKind, r.Children[0].Kind)
+	assert.Equal(t, 1, r.Children[0].Rc)
+	assert.Equal(t, OrKind, r.Children[1].Kind)
+	require.Len(t, r.Children[1].Children, 2)
+	p := r.Children[1].Children[0]
+	assert.Equal(t, PipeKind, p.Kind)
+	require.Len(t, p.Children, 2)
+	assert.Equal(t, "bar\n", p.Children[0].Stdout)
+	assert.Equal(t, "bar\n", sed.StdinRecord())
+	assert.Equal(t, "bar\n", p.Children[1].Stdout)
+	assert.Empty(t, r.Children[1].Children[1].Attempts)
+	assert.False(t, r.Start.IsZero())
+
+	out, err := r.JSON()
+	require.NoError(t, err)
+	var decoded ExecutionReport
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, r.Children[0].Children[1].Argv, decoded.Children[0].Children[1].Argv)
+
+	out, err = r.JUnit("build")
+	require.NoError(t, err)
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(out, &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	assert.Equal(t, "build", suite.Name)
+	assert.Equal(t, 5, suite.Tests)
+	assert.Equal(t, 1, suite.Failures)
+	assert.Equal(t, "serial.parallel.cmd", suite.Cases[1].Classname)
+	assert.NotNil(t, suite.Cases[1].Failure)
+	assert.NotNil(t, suite.Cases[4].Skipped)
+	assert.True(t, strings.HasPrefix(string(out), "<?xml"))
+}
+
+func TestReport_TruncatedOutputs(t *testing.T) {
+	c := Sh("seq 1 2000")
+	_, err := c.BlockRun()
+	require.NoError(t, err)
+	r := Report(c)
+	assert.LessOrEqual(t, len(r.Stdout), ReportOutputMaxLength)
+}
@@ -0,0 +1,69 @@
+package cmdz
+
+import (
+	"math"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// Retry only failed attempts exiting with one of supplied codes.
+func RetryOnCodes(codes ...int) RetryPredicate {
+	return func(rc int, stdout, stderr []byte) bool {
+		for _, code := range codes {
+			if rc == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Retry only failed attempts which stderr match the supplied regexp.
+func RetryOnStderr(pattern string) RetryPredicate {
+	r := regexp.MustCompile(pattern)
+	return func(rc int, stdout, stderr []byte) bool {
+		return r.Match(stderr)
+	}
+}
+
+// Retry failed attempts if any of the supplied predicates is true.
+func RetryOnAny(predicates ...RetryPredicate) RetryPredicate {
+	return func(rc int, stdout, stderr []byte) bool {
+		for _, p := range predicates {
+			if p(rc, stdout, stderr) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Should a failed attempt be retried ?
+func retryable(cfg *config, rc int, stdout, stderr []byte) bool {
+	if rc == 0 {
+		return false
+	}
+	if cfg.retryPredicate == nil {
+		return true
+	}
+	return cfg.retryPredicate(rc, stdout, stderr)
+}
+
+// Delay to wait before the retry number n (starting at 1).
+// Delay grows exponentially with backoff factor, is capped by max delay, then jittered.
+func retryDelay(cfg *config, n int) time.Duration {
+	delay := time.Duration(cfg.retryDelayInMs) * time.Millisecond
+	if cfg.retryFactor > 1 && n > 1 {
+		delay = time.Duration(float64(delay) * math.Pow(cfg.retryFactor, float64(n-1)))
+	}
+	if cfg.retryMaxDelay > 0 && (delay > cfg.retryMaxDelay || delay < 0) {
+		delay = cfg.retryMaxDelay
+	}
+	if cfg.retryJitter > 0 && delay > 0 {
+		// Randomize delay in range [delay * (1 - jitter) ; delay * (1 + jitter)]
+		jitter := math.Min(cfg.retryJitter, 1)
+		delay = time.Duration(float64(delay) * (1 - jitter + 2*jitter*rand.Float64()))
+	}
+	return delay
+}
@@ -0,0 +1,132 @@
+package cmdz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cfg := config{retryDelayInMs: 10}
+	assert.Equal(t, 10*time.Millisecond, retryDelay(&cfg, 1))
+	assert.Equal(t, 10*time.Millisecond, retryDelay(&cfg, 3))
+
+	cfg.retryFactor = 2
+	assert.Equal(t, 10*time.Millisecond, retryDelay(&cfg, 1))
+	assert.Equal(t, 20*time.Millisecond, retryDelay(&cfg, 2))
+	assert.Equal(t, 40*time.Millisecond, retryDelay(&cfg, 3))
+
+	cfg.retryMaxDelay = 30 * time.Millisecond
+	assert.Equal(t, 30*time.Millisecond, retryDelay(&cfg, 3))
+	assert.Equal(t, 30*time.Millisecond, retryDelay(&cfg, 100))
+
+	cfg.retryJitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := retryDelay(&cfg, 2)
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+		assert.LessOrEqual(t, d, 30*time.Millisecond)
+	}
+}
+
+func TestRetryPredicates(t *testing.T) {
+	p := RetryOnCodes(75, 76)
+	assert.True(t, p(75, nil, nil))
+	assert.False(t, p(1, nil, nil))
+
+	p = RetryOnStderr("connection reset")
+	assert.True(t, p(1, nil, []byte("error: connection reset by peer")))
+	assert.False(t, p(1, []byte("connection reset"), []byte("error")))
+
+	p = RetryOnAny(RetryOnCodes(75), RetryOnStderr("connection reset"))
+	assert.True(t, p(75, nil, nil))
+	assert.True(t, p(1, nil, []byte("connection reset")))
+	assert.False(t, p(1, nil, []byte("denied")))
+}
+
+func TestBlockRun_RetryIf(t *testing.T) {
+	e := Sh(">&2 echo connection reset ; exit 1").Retries(2, 1).RetryIf(RetryOnStderr("connection reset"))
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []int{1, 1, 1}, e.ResultCodes())
+	assert.Len(t, e.StartTimes(), 3)
+	assert.Len(t, e.Durations(), 3)
+
+	e = Sh("exit 3").Retries(2, 1).RetryIf(RetryOnCodes(75))
+	rc, err = e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+	assert.Equal(t, []int{3}, e.ResultCodes())
+}
+
+func TestBlockRun_Backoff(t *testing.T) {
+	e := Cmd("false").Retries(3, 10).Backoff(2, time.Second, 0)
+	start := time.Now()
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []int{1, 1, 1, 1}, e.ResultCodes())
+	// 10 + 20 + 40 ms
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+	startTimes := e.StartTimes()
+	require.Len(t, startTimes, 4)
+	assert.GreaterOrEqual(t, startTimes[3].Sub(startTimes[2]), 40*time.Millisecond)
+}
+
+func TestBlockRun_RetryTimeout(t *testing.T) {
+	e := Cmd("false").Retries(10, 30).RetryTimeout(100 * time.Millisecond)
+	start := time.Now()
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	assert.Less(t, len(e.ResultCodes()), 5)
+	assert.Greater(t, len(e.ResultCodes()), 1)
+}
+
+func TestSequences_RetryIf(t *testing.T) {
+	newCmds := func() (Executer, Executer) {
+		return Sh("exit 75"), Sh("exit 3")
+	}
+
+	f1, f2 := newCmds()
+	s := Serial(f1, f2)
+	s.Retries(2, 1).RetryIf(RetryOnCodes(75))
+	_, err := s.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []int{75, 75, 75}, f1.ResultCodes())
+	assert.Equal(t, []int{3}, f2.ResultCodes())
+	assert.Equal(t, []int{75, 75, 75, 3}, s.ResultCodes())
+	assert.Len(t, s.StartTimes(), 4)
+	assert.Len(t, s.Durations(), 4)
+
+	f1, f2 = newCmds()
+	a := And(f1, f2)
+	a.Retries(2, 1).RetryIf(RetryOnCodes(75))
+	_, err = a.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []int{75, 75, 75}, f1.ResultCodes())
+	assert.Equal(t, []int{75, 75, 75}, a.ResultCodes())
+	assert.Len(t, a.StartTimes(), 3)
+
+	f1, f2 = newCmds()
+	o := Or(f1, f2)
+	o.Retries(2, 1).RetryIf(RetryOnCodes(75))
+	_, err = o.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []int{75, 75, 75, 3}, o.ResultCodes())
+	assert.Len(t, o.StartTimes(), 4)
+	assert.Len(t, o.Durations(), 4)
+
+	f1, f2 = newCmds()
+	p := Parallel(f1, f2)
+	p.Retries(2, 1).RetryIf(RetryOnCodes(75))
+	_, err = p.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, []int{75, 75, 75}, f1.ResultCodes())
+	assert.Equal(t, []int{3}, f2.ResultCodes())
+	assert.Len(t, p.StartTimes(), 4)
+	assert.Len(t, p.Durations(), 4)
+}
@@ -1,12 +1,17 @@
 package cmdz
 
 import (
+	"context"
 	"io"
 	"log"
+	"os"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/mxbossard/utilz/anzi"
 	"github.com/mxbossard/utilz/collectionz"
+	"github.com/mxbossard/utilz/printz"
 	"github.com/mxbossard/utilz/promiz"
 	"github.com/mxbossard/utilz/ztring"
 )
@@ -117,7 +122,7 @@ func (e *seq) Stdout() io.Writer {
 	return e.config.stdout
 }
 func (e *seq) Stderr() io.Writer {
-	return e.config.stdout
+	return e.config.stderr
 }
 
 // ----- Recorder methods -----
@@ -142,6 +147,26 @@ func (s *seq) StderrRecord() string {
 	return strings.Join(stderrs, "")
 }
 
+// StdoutFile return the spill file of the last outer which spilled its stdout.
+func (s *seq) StdoutFile() string {
+	for i := len(s.outers) - 1; i >= 0; i-- {
+		if path := s.outers[i].StdoutFile(); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// StderrFile return the spill file of the last outer which spilled its stderr.
+func (s *seq) StderrFile() string {
+	for i := len(s.outers) - 1; i >= 0; i-- {
+		if path := s.outers[i].StderrFile(); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
 func (s *seq) reset() {
 	for _, e := range s.execs {
 		e.reset()
@@ -152,6 +177,11 @@ func (s *seq) fallback(cfg *config) {
 	s.fallbackConfig = cfg
 }
 
+// Secrets of the sequence, children get them through fallback at run time.
+func (s *seq) secrets() []string {
+	return mergeConfigs(&s.config, s.fallbackConfig).secrets
+}
+
 type serialSeq struct {
 	*seq
 }
@@ -188,11 +218,109 @@ func (s *serialSeq) Retries(count, delayInMs int) Executer {
 	return s
 }
 
+func (s *serialSeq) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	s.config.retryFactor = factor
+	s.config.retryMaxDelay = maxDelay
+	s.config.retryJitter = jitter
+	return s
+}
+
+func (s *serialSeq) RetryTimeout(total time.Duration) Executer {
+	s.config.retryMaxDuration = total
+	return s
+}
+
+func (s *serialSeq) RetryIf(predicate RetryPredicate) Executer {
+	s.config.retryPredicate = predicate
+	return s
+}
+
 func (s *serialSeq) Timeout(duration time.Duration) Executer {
 	s.config.timeout = duration
 	return s
 }
 
+func (s *serialSeq) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	s.config.termSignal = signal
+	s.config.termGrace = grace
+	return s
+}
+
+func (s *serialSeq) Context(ctx context.Context) Executer {
+	s.config.ctx = ctx
+	return s
+}
+
+func (s *serialSeq) Dir(path string) Executer {
+	s.config.dir = path
+	return s
+}
+
+func (s *serialSeq) Nice(n int) Executer {
+	s.config.nice = n
+	return s
+}
+
+func (s *serialSeq) Umask(mask os.FileMode) Executer {
+	s.config.umask = &mask
+	return s
+}
+
+func (s *serialSeq) Limits(limits ResourceLimits) Executer {
+	s.config.limits = limits
+	return s
+}
+
+func (s *serialSeq) InheritEnv(allowed ...string) Executer {
+	if len(allowed) == 0 {
+		s.config.envMode = envInheritAll
+	} else {
+		s.config.envMode = envInheritAllowed
+	}
+	s.config.envAllowed = allowed
+	return s
+}
+
+func (s *serialSeq) CleanEnv() Executer {
+	s.config.envMode = envClean
+	s.config.envAllowed = nil
+	return s
+}
+
+func (s *serialSeq) Secrets(values ...string) Executer {
+	s.config.secrets = append(s.config.secrets, values...)
+	// Current children are redacted before the first run, later ones get secrets by fallback
+	for _, e := range s.execs {
+		e.Secrets(values...)
+	}
+	return s
+}
+
+func (s *serialSeq) Hooks(hooks ...Hooks) Executer {
+	s.config.hooks = append(s.config.hooks, hooks...)
+	return s
+}
+
+func (s *serialSeq) DryRun(printer printz.Printer) Executer {
+	s.config.dryRun = dryRunPrinter(printer)
+	return s
+}
+
+func (s *serialSeq) DryRunRc(rc int) Executer {
+	s.config.dryRunRc = &rc
+	return s
+}
+
+func (s *serialSeq) RecordLimit(maxBytes int) Executer {
+	s.config.recordLimit = maxBytes
+	return s
+}
+
+func (s *serialSeq) SpillRecords() Executer {
+	s.config.spillRecords = true
+	return s
+}
+
 func (s *serialSeq) AddEnv(key, value string) Executer {
 	for _, e := range s.execs {
 		e.AddEnv(key, value)
@@ -221,6 +349,21 @@ func (s *serialSeq) CombinedOutputs() Executer {
 	return s
 }
 
+func (s *serialSeq) Mock(m *Mock) Executer {
+	s.config.mock = m
+	return s
+}
+
+func (s *serialSeq) Record(r *Recording) Executer {
+	s.config.recording = r
+	return s
+}
+
+func (s *serialSeq) InProcess(fn ProcessFunc) Executer {
+	s.config.inProcess = fn
+	return s
+}
+
 func (s *serialSeq) ErrorOnFailure(enable bool) Executer {
 	s.config.errorOnFailure = enable
 	return s
@@ -236,7 +379,7 @@ func (s *serialSeq) Add(execs ...Executer) *serialSeq {
 }
 
 func (s serialSeq) String() string {
-	return ztring.JoinStringers(s.seq.execs, "\n")
+	return redact(ztring.JoinStringers(s.seq.execs, "\n"), s.secrets())
 }
 
 func (s serialSeq) ReportError() string {
@@ -253,7 +396,15 @@ func (s *serialSeq) init() {
 }
 
 func (s *serialSeq) BlockRun() (rc int, err error) {
-	mergedConfig := mergeConfigs(&s.config, s.fallbackConfig)
+	return s.blockRunCtx(nil)
+}
+
+func (s *serialSeq) blockRunCtx(ctx context.Context) (rc int, err error) {
+	header := "serial"
+	if s.failFast {
+		header = "and"
+	}
+	mergedConfig := dryRunSeq(withRunCtx(mergeConfigs(&s.config, s.fallbackConfig), ctx), header)
 	for _, exec := range s.seq.execs {
 		exec.fallback(mergedConfig)
 	}
@@ -261,6 +412,7 @@ func (s *serialSeq) BlockRun() (rc int, err error) {
 	if len(s.seq.execs) > 0 {
 		rc, err = blockSerial(s.failFast, s.seq.execs...)
 	}
+	s.status = rc
 	return
 }
 
@@ -289,7 +441,7 @@ func (e *serialSeq) ExitCode() int {
 
 func (e *serialSeq) StartTimes() (times []time.Time) {
 	for _, exec := range e.execs {
-		times = append(times, exec.StartTime())
+		times = append(times, exec.StartTimes()...)
 	}
 	return
 }
@@ -300,7 +452,7 @@ func (e *serialSeq) StartTime() time.Time {
 
 func (e *serialSeq) Durations() (durations []time.Duration) {
 	for _, exec := range e.execs {
-		durations = append(durations, exec.Duration())
+		durations = append(durations, exec.Durations()...)
 	}
 	return
 }
@@ -318,7 +470,7 @@ type andSeq struct {
 }
 
 func (s andSeq) String() string {
-	return ztring.JoinStringers(s.seq.execs, " && ")
+	return redact(ztring.JoinStringers(s.seq.execs, " && "), s.secrets())
 }
 
 type orSeq struct {
@@ -353,7 +505,7 @@ func (e *orSeq) SetOutputs(stdout, stderr io.Writer) Executer {
 }
 
 func (s orSeq) String() string {
-	return ztring.JoinStringers(s.seq.execs, " || ")
+	return redact(ztring.JoinStringers(s.seq.execs, " || "), s.secrets())
 }
 
 func (s *orSeq) Retries(count, delayInMs int) Executer {
@@ -362,11 +514,108 @@ func (s *orSeq) Retries(count, delayInMs int) Executer {
 	return s
 }
 
+func (s *orSeq) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	s.config.retryFactor = factor
+	s.config.retryMaxDelay = maxDelay
+	s.config.retryJitter = jitter
+	return s
+}
+
+func (s *orSeq) RetryTimeout(total time.Duration) Executer {
+	s.config.retryMaxDuration = total
+	return s
+}
+
+func (s *orSeq) RetryIf(predicate RetryPredicate) Executer {
+	s.config.retryPredicate = predicate
+	return s
+}
+
 func (s *orSeq) Timeout(duration time.Duration) Executer {
 	s.config.timeout = duration
 	return s
 }
 
+func (s *orSeq) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	s.config.termSignal = signal
+	s.config.termGrace = grace
+	return s
+}
+
+func (s *orSeq) Context(ctx context.Context) Executer {
+	s.config.ctx = ctx
+	return s
+}
+
+func (s *orSeq) Dir(path string) Executer {
+	s.config.dir = path
+	return s
+}
+
+func (s *orSeq) Nice(n int) Executer {
+	s.config.nice = n
+	return s
+}
+
+func (s *orSeq) Umask(mask os.FileMode) Executer {
+	s.config.umask = &mask
+	return s
+}
+
+func (s *orSeq) Limits(limits ResourceLimits) Executer {
+	s.config.limits = limits
+	return s
+}
+
+func (s *orSeq) InheritEnv(allowed ...string) Executer {
+	if len(allowed) == 0 {
+		s.config.envMode = envInheritAll
+	} else {
+		s.config.envMode = envInheritAllowed
+	}
+	s.config.envAllowed = allowed
+	return s
+}
+
+func (s *orSeq) CleanEnv() Executer {
+	s.config.envMode = envClean
+	s.config.envAllowed = nil
+	return s
+}
+
+func (s *orSeq) Secrets(values ...string) Executer {
+	s.config.secrets = append(s.config.secrets, values...)
+	for _, e := range s.execs {
+		e.Secrets(values...)
+	}
+	return s
+}
+
+func (s *orSeq) Hooks(hooks ...Hooks) Executer {
+	s.config.hooks = append(s.config.hooks, hooks...)
+	return s
+}
+
+func (s *orSeq) DryRun(printer printz.Printer) Executer {
+	s.config.dryRun = dryRunPrinter(printer)
+	return s
+}
+
+func (s *orSeq) DryRunRc(rc int) Executer {
+	s.config.dryRunRc = &rc
+	return s
+}
+
+func (s *orSeq) RecordLimit(maxBytes int) Executer {
+	s.config.recordLimit = maxBytes
+	return s
+}
+
+func (s *orSeq) SpillRecords() Executer {
+	s.config.spillRecords = true
+	return s
+}
+
 func (s *orSeq) AddEnv(key, value string) Executer {
 	for _, e := range s.execs {
 		e.AddEnv(key, value)
@@ -395,6 +644,21 @@ func (s *orSeq) CombinedOutputs() Executer {
 	return s
 }
 
+func (s *orSeq) Mock(m *Mock) Executer {
+	s.config.mock = m
+	return s
+}
+
+func (s *orSeq) Record(r *Recording) Executer {
+	s.config.recording = r
+	return s
+}
+
+func (s *orSeq) InProcess(fn ProcessFunc) Executer {
+	s.config.inProcess = fn
+	return s
+}
+
 func (s *orSeq) ErrorOnFailure(enable bool) Executer {
 	s.config.errorOnFailure = enable
 	return s
@@ -421,7 +685,11 @@ func (s *orSeq) init() {
 }
 
 func (s *orSeq) BlockRun() (rc int, err error) {
-	mergedConfig := mergeConfigs(&s.config, s.fallbackConfig)
+	return s.blockRunCtx(nil)
+}
+
+func (s *orSeq) blockRunCtx(ctx context.Context) (rc int, err error) {
+	mergedConfig := dryRunSeq(withRunCtx(mergeConfigs(&s.config, s.fallbackConfig), ctx), "or")
 	for _, exec := range s.seq.execs {
 		exec.fallback(mergedConfig)
 	}
@@ -429,6 +697,7 @@ func (s *orSeq) BlockRun() (rc int, err error) {
 	if len(s.seq.execs) > 0 {
 		rc, err = blockOr(s.seq.execs...)
 	}
+	s.status = rc
 	return
 }
 
@@ -457,7 +726,7 @@ func (e *orSeq) ExitCode() int {
 
 func (e *orSeq) StartTimes() (times []time.Time) {
 	for _, exec := range e.execs {
-		times = append(times, exec.StartTime())
+		times = append(times, exec.StartTimes()...)
 	}
 	return
 }
@@ -468,7 +737,7 @@ func (e *orSeq) StartTime() time.Time {
 
 func (e *orSeq) Durations() (durations []time.Duration) {
 	for _, exec := range e.execs {
-		durations = append(durations, exec.Duration())
+		durations = append(durations, exec.Durations()...)
 	}
 	return
 }
@@ -484,7 +753,11 @@ func (e *orSeq) Duration() time.Duration {
 type parallelSeq struct {
 	*seq
 
-	forkCount int
+	forkCount      int
+	multiplexed    bool
+	groupedOutputs bool
+	labels         []string
+	colors         []anzi.Color
 }
 
 func (e *parallelSeq) SetInput(stdin io.Reader) Executer {
@@ -527,11 +800,108 @@ func (s *parallelSeq) Retries(count, delayInMs int) Executer {
 	return s
 }
 
+func (s *parallelSeq) Backoff(factor float64, maxDelay time.Duration, jitter float64) Executer {
+	s.config.retryFactor = factor
+	s.config.retryMaxDelay = maxDelay
+	s.config.retryJitter = jitter
+	return s
+}
+
+func (s *parallelSeq) RetryTimeout(total time.Duration) Executer {
+	s.config.retryMaxDuration = total
+	return s
+}
+
+func (s *parallelSeq) RetryIf(predicate RetryPredicate) Executer {
+	s.config.retryPredicate = predicate
+	return s
+}
+
 func (s *parallelSeq) Timeout(duration time.Duration) Executer {
 	s.config.timeout = duration
 	return s
 }
 
+func (s *parallelSeq) Termination(signal syscall.Signal, grace time.Duration) Executer {
+	s.config.termSignal = signal
+	s.config.termGrace = grace
+	return s
+}
+
+func (s *parallelSeq) Context(ctx context.Context) Executer {
+	s.config.ctx = ctx
+	return s
+}
+
+func (s *parallelSeq) Dir(path string) Executer {
+	s.config.dir = path
+	return s
+}
+
+func (s *parallelSeq) Nice(n int) Executer {
+	s.config.nice = n
+	return s
+}
+
+func (s *parallelSeq) Umask(mask os.FileMode) Executer {
+	s.config.umask = &mask
+	return s
+}
+
+func (s *parallelSeq) Limits(limits ResourceLimits) Executer {
+	s.config.limits = limits
+	return s
+}
+
+func (s *parallelSeq) InheritEnv(allowed ...string) Executer {
+	if len(allowed) == 0 {
+		s.config.envMode = envInheritAll
+	} else {
+		s.config.envMode = envInheritAllowed
+	}
+	s.config.envAllowed = allowed
+	return s
+}
+
+func (s *parallelSeq) CleanEnv() Executer {
+	s.config.envMode = envClean
+	s.config.envAllowed = nil
+	return s
+}
+
+func (s *parallelSeq) Secrets(values ...string) Executer {
+	s.config.secrets = append(s.config.secrets, values...)
+	for _, e := range s.execs {
+		e.Secrets(values...)
+	}
+	return s
+}
+
+func (s *parallelSeq) Hooks(hooks ...Hooks) Executer {
+	s.config.hooks = append(s.config.hooks, hooks...)
+	return s
+}
+
+func (s *parallelSeq) DryRun(printer printz.Printer) Executer {
+	s.config.dryRun = dryRunPrinter(printer)
+	return s
+}
+
+func (s *parallelSeq) DryRunRc(rc int) Executer {
+	s.config.dryRunRc = &rc
+	return s
+}
+
+func (s *parallelSeq) RecordLimit(maxBytes int) Executer {
+	s.config.recordLimit = maxBytes
+	return s
+}
+
+func (s *parallelSeq) SpillRecords() Executer {
+	s.config.spillRecords = true
+	return s
+}
+
 func (s *parallelSeq) AddEnv(key, value string) Executer {
 	for _, e := range s.execs {
 		e.AddEnv(key, value)
@@ -564,11 +934,49 @@ func (s *parallelSeq) CombinedOutputs() Executer {
 	return s
 }
 
+func (s *parallelSeq) Mock(m *Mock) Executer {
+	s.config.mock = m
+	return s
+}
+
+func (s *parallelSeq) Record(r *Recording) Executer {
+	s.config.recording = r
+	return s
+}
+
+func (s *parallelSeq) InProcess(fn ProcessFunc) Executer {
+	s.config.inProcess = fn
+	return s
+}
+
 func (s *parallelSeq) Fork(count int) *parallelSeq {
 	s.forkCount = count
 	return s
 }
 
+// Multiplex children outputs: each line is prefixed by the child label and written atomically.
+// If grouped, the whole output of each child is written once it ended.
+func (s *parallelSeq) Multiplex(grouped bool) *parallelSeq {
+	s.multiplexed = true
+	s.groupedOutputs = grouped
+	return s
+}
+
+// Labels prefixing multiplexed output lines of children, in children order. Default to child index.
+func (s *parallelSeq) Labels(labels ...string) *parallelSeq {
+	s.labels = labels
+	return s
+}
+
+// Colors of the labels prefixing multiplexed output lines, cycled in children order. Default to LabelColors.
+func (s *parallelSeq) Colors(colors ...anzi.Color) *parallelSeq {
+	if len(colors) == 0 {
+		colors = LabelColors
+	}
+	s.colors = colors
+	return s
+}
+
 func (s *parallelSeq) Add(execs ...Executer) *parallelSeq {
 	s.execs = append(s.execs, execs...)
 	s.inners = s.execs
@@ -577,7 +985,7 @@ func (s *parallelSeq) Add(execs ...Executer) *parallelSeq {
 }
 
 func (s parallelSeq) String() string {
-	return ztring.JoinStringers(s.seq.execs, "\n")
+	return redact(ztring.JoinStringers(s.seq.execs, "\n"), s.secrets())
 }
 
 func (s parallelSeq) ReportError() string {
@@ -594,13 +1002,29 @@ func (s *parallelSeq) init() {
 }
 
 func (s *parallelSeq) BlockRun() (rc int, err error) {
+	return s.blockRunCtx(nil)
+}
+
+func (s *parallelSeq) blockRunCtx(ctx context.Context) (rc int, err error) {
+	mergedConfig := dryRunSeq(withRunCtx(mergeConfigs(&s.config, s.fallbackConfig), ctx), "parallel")
 	for _, exec := range s.seq.execs {
-		exec.fallback(&s.config)
+		exec.fallback(mergedConfig)
 	}
 	s.reset()
-	if len(s.seq.execs) > 0 {
-		rc, err = blockParallel(s.failFast, s.forkCount, s.seq.execs...)
+	execs := s.seq.execs
+	forkCount := s.forkCount
+	if mergedConfig.dryRun != nil {
+		// Print the simulated executions in order
+		forkCount = 1
+	} else if s.multiplexed {
+		var restore func()
+		execs, restore = s.multiplex(mergedConfig)
+		defer restore()
+	}
+	if len(execs) > 0 {
+		rc, err = blockParallel(s.failFast, forkCount, execs...)
 	}
+	s.status = rc
 	return
 }
 
@@ -628,7 +1052,7 @@ func (e *parallelSeq) ExitCode() int {
 
 func (e *parallelSeq) StartTimes() (times []time.Time) {
 	for _, exec := range e.execs {
-		times = append(times, exec.StartTime())
+		times = append(times, exec.StartTimes()...)
 	}
 	return
 }
@@ -639,7 +1063,7 @@ func (e *parallelSeq) StartTime() time.Time {
 
 func (e *parallelSeq) Durations() (durations []time.Duration) {
 	for _, exec := range e.execs {
-		durations = append(durations, exec.Duration())
+		durations = append(durations, exec.Durations()...)
 	}
 	return
 }
@@ -6,6 +6,10 @@ import (
 	//"context"
 	//"log"
 	//"os/exec"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -157,6 +161,33 @@ func TestSerial_FailFast(t *testing.T) {
 	assert.Equal(t, "foo\n", s.StdoutRecord())
 }
 
+func TestAnd(t *testing.T) {
+	e1 := Cmd("echo", "foo")
+	e2 := Cmd("echo", "bar")
+	f1 := Cmd("false")
+
+	// Each exec run once
+	a := And(e1, e2)
+	rc, err := a.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []int{0}, e1.ResultCodes())
+	assert.Equal(t, []int{0}, e2.ResultCodes())
+	assert.Equal(t, "foo\nbar\n", a.StdoutRecord())
+	assert.Equal(t, "echo foo && echo bar", a.String())
+
+	// Stop at first failure
+	e1, e2 = Cmd("echo", "foo"), Cmd("echo", "bar")
+	a = And(e1, f1, e2)
+	rc, err = a.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 1, rc)
+	assert.Equal(t, []int{0}, e1.ResultCodes())
+	assert.Equal(t, []int{1}, f1.ResultCodes())
+	assert.Equal(t, []int(nil), e2.ResultCodes())
+	assert.Equal(t, "foo\n", a.StdoutRecord())
+}
+
 func TestSerial_ErrorOnFailure(t *testing.T) {
 	e1 := Cmd("echo", "foo")
 	f := Cmd("/bin/false").ErrorOnFailure(true)
@@ -463,3 +494,44 @@ func Test_FallbackPersitence(t *testing.T) {
 	// Retries should be 0 by default fallback should not persist
 	assert.Equal(t, []int{1}, f.ResultCodes(), "Undesired fallback persistance")
 }
+
+func TestSequences_Context(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	sequences := map[string]func() Executer{
+		"serial":   func() Executer { return Serial(Cmd("sleep", "5"), Cmd("touch", marker)) },
+		"and":      func() Executer { return And(Cmd("true"), Cmd("sleep", "5"), Cmd("touch", marker)) },
+		"or":       func() Executer { return Or(Cmd("false"), Cmd("sleep", "5"), Cmd("touch", marker)) },
+		"parallel": func() Executer { return Parallel(Cmd("sleep", "5"), Serial(Cmd("sleep", "5"), Cmd("touch", marker))) },
+		"graph": func() Executer {
+			return Graph().Node("sleep", Cmd("sleep", "5")).Node("touch", Cmd("touch", marker), "sleep")
+		},
+		"pipe":   func() Executer { return Serial(Cmd("sleep", "5").Pipe(Cmd("cat")), Cmd("touch", marker)) },
+		"nested": func() Executer { return Serial(Parallel(Or(Cmd("false"), Cmd("sleep", "5"))), Cmd("touch", marker)) },
+	}
+	for name, build := range sequences {
+		build := build
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s := build().Context(ctx)
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			_, err := s.BlockRun()
+			assert.Less(t, time.Since(start), 3*time.Second)
+			assert.True(t, errors.Is(err, context.Canceled), "error: %v", err)
+			_, statErr := os.Stat(marker)
+			assert.True(t, os.IsNotExist(statErr), "pending child was started")
+		})
+	}
+}
+
+func TestSequences_ContextPromise(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Parallel(Cmd("sleep", "5"), Cmd("sleep", "5")).Context(ctx).AsyncRun()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := p.Await(context.Background())
+	assert.Less(t, time.Since(start), 3*time.Second)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,422 @@
+package cmdz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mxbossard/utilz/errorz"
+)
+
+const defaultReadyTimeout = 10 * time.Second
+
+type ServiceState string
+
+const (
+	ServiceStarting   = ServiceState("starting")
+	ServiceReady      = ServiceState("ready")
+	ServiceExited     = ServiceState("exited")
+	ServiceRestarting = ServiceState("restarting")
+	ServiceStopped    = ServiceState("stopped")
+	ServiceFailed     = ServiceState("failed")
+)
+
+// ServiceEvent describe a lifecycle change of a service.
+type ServiceEvent struct {
+	State    ServiceState
+	Time     time.Time
+	Pid      int
+	Rc       int
+	Restarts int
+	Err      error
+}
+
+type RestartPolicy int
+
+const (
+	RestartNever RestartPolicy = iota
+	RestartOnFailure
+	RestartAlways
+)
+
+func (p RestartPolicy) restart(rc int) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return rc != 0
+	}
+	return false
+}
+
+// ReadinessProbe block until the service is ready or ctx is done. Ctx is done when the ready timeout expires or the process exited.
+type ReadinessProbe = func(ctx context.Context) error
+
+// service supervise a long running process: readiness probes, restarts and termination.
+type service struct {
+	mu     sync.Mutex
+	exec   *cmdz
+	name   string
+	stdout *expectStream
+
+	probes       []ReadinessProbe
+	readyTimeout time.Duration
+	policy       RestartPolicy
+	maxRestarts  int
+	backoff      config
+	listeners    []func(ServiceEvent)
+
+	started  bool
+	stopping bool
+	pid      int
+	rc       int
+	err      error
+	events   []ServiceEvent
+	ready    chan error
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Service build a supervisor of the long running process e.
+func Service(e *cmdz) *service {
+	return &service{
+		exec:         e,
+		name:         e.String(),
+		readyTimeout: defaultReadyTimeout,
+		ready:        make(chan error, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// ReadyOnOutput consider the service ready once its stdout match pattern.
+func (s *service) ReadyOnOutput(pattern string) *service {
+	re := regexp.MustCompile(pattern)
+	return s.ReadyIf(func(ctx context.Context) error {
+		for {
+			groups, changed := s.stdout.match(re)
+			if groups != nil {
+				return nil
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return fmt.Errorf("Service output never matched /%s/: %q ! Caused by: %w", pattern, s.stdout.unmatched(), ctx.Err())
+			}
+		}
+	})
+}
+
+// ReadyOnPort consider the service ready once a TCP connection to address succeed.
+func (s *service) ReadyOnPort(address string) *service {
+	return s.ReadyIf(func(ctx context.Context) error {
+		dialer := net.Dialer{Timeout: 100 * time.Millisecond}
+		for {
+			conn, err := dialer.DialContext(ctx, "tcp", address)
+			if err == nil {
+				return conn.Close()
+			}
+			select {
+			case <-time.After(20 * time.Millisecond):
+			case <-ctx.Done():
+				return fmt.Errorf("Service port %s never opened ! Caused by: %w", address, ctx.Err())
+			}
+		}
+	})
+}
+
+// ReadyOnFile consider the service ready once path exists.
+func (s *service) ReadyOnFile(path string) *service {
+	return s.ReadyIf(func(ctx context.Context) error {
+		for {
+			if _, err := os.Stat(path); err == nil {
+				return nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("Unable to check service file %s ! Caused by: %w", path, err)
+			}
+			select {
+			case <-time.After(20 * time.Millisecond):
+			case <-ctx.Done():
+				return fmt.Errorf("Service file %s never appeared ! Caused by: %w", path, ctx.Err())
+			}
+		}
+	})
+}
+
+// ReadyIf consider the service ready once probe returned without error. Probes are checked in order.
+func (s *service) ReadyIf(probe ReadinessProbe) *service {
+	s.probes = append(s.probes, probe)
+	return s
+}
+
+// ReadyTimeout bound the time given to probes for the service to be ready. Default to 10s.
+func (s *service) ReadyTimeout(timeout time.Duration) *service {
+	s.readyTimeout = timeout
+	return s
+}
+
+// Restart the process when it exits according to policy, at most maxRestarts times (unlimited if 0), waiting delay before restarting.
+func (s *service) Restart(policy RestartPolicy, maxRestarts int, delay time.Duration) *service {
+	s.policy = policy
+	s.maxRestarts = maxRestarts
+	s.backoff.retryDelayInMs = int(delay.Milliseconds())
+	return s
+}
+
+// Backoff multiply the restart delay by factor on each restart, up to maxDelay, randomized by +/- jitter ratio.
+func (s *service) Backoff(factor float64, maxDelay time.Duration, jitter float64) *service {
+	s.backoff.retryFactor = factor
+	s.backoff.retryMaxDelay = maxDelay
+	s.backoff.retryJitter = jitter
+	return s
+}
+
+// OnEvent call listener on each lifecycle event.
+func (s *service) OnEvent(listener func(ServiceEvent)) *service {
+	s.listeners = append(s.listeners, listener)
+	return s
+}
+
+func (s *service) emit(event ServiceEvent) {
+	event.Time = time.Now()
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	listeners := s.listeners
+	s.mu.Unlock()
+	for _, l := range listeners {
+		l(event)
+	}
+}
+
+// Events return all lifecycle events emitted so far.
+func (s *service) Events() []ServiceEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ServiceEvent(nil), s.events...)
+}
+
+// Start the process and wait until it is ready. If it is not ready, the process is stopped.
+func (s *service) Start() error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return fmt.Errorf("Service already started: [%s] !", s.name)
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	stdout := s.exec.Stdout()
+	s.stdout = newExpectStream("stdout", stdout)
+	s.exec.SetStdout(s.stdout)
+	go func() {
+		s.run()
+		s.exec.SetStdout(stdout)
+		close(s.done)
+	}()
+
+	err := <-s.ready
+	if err != nil {
+		_ = s.Stop(s.exec.config.termGrace)
+		return err
+	}
+	return nil
+}
+
+// Supervise process executions until it should not be restarted anymore.
+func (s *service) run() {
+	for restarts := 0; ; restarts++ {
+		s.stdout.reset()
+		cfg := s.exec.prepare()
+		cfg.retries = 0
+		s.emit(ServiceEvent{State: ServiceStarting, Restarts: restarts})
+
+		started := make(chan error, 1)
+		exited := make(chan struct{})
+		probed := make(chan struct{})
+		go func() {
+			s.awaitReady(started, exited, restarts)
+			close(probed)
+		}()
+		rc, err := s.exec.blockRunAttempts(cfg, func(err error) {
+			if err == nil && s.exec.cmd.Process != nil {
+				s.setPid(s.exec.cmd.Process.Pid)
+			}
+			started <- err
+		})
+		pid := s.setPid(0)
+		if f, ok := err.(failure); ok {
+			rc, err = f.Rc, nil
+		}
+		s.mu.Lock()
+		s.rc, s.err = rc, err
+		stopping := s.stopping
+		s.mu.Unlock()
+		close(exited)
+		<-probed
+
+		if err != nil {
+			s.emit(ServiceEvent{State: ServiceFailed, Pid: pid, Rc: rc, Restarts: restarts, Err: err})
+			return
+		}
+		s.emit(ServiceEvent{State: ServiceExited, Pid: pid, Rc: rc, Restarts: restarts})
+		if stopping {
+			s.emit(ServiceEvent{State: ServiceStopped, Pid: pid, Rc: rc, Restarts: restarts})
+			return
+		}
+		if !s.policy.restart(rc) || s.maxRestarts > 0 && restarts >= s.maxRestarts {
+			if rc != 0 {
+				s.emit(ServiceEvent{State: ServiceFailed, Pid: pid, Rc: rc, Restarts: restarts})
+			}
+			return
+		}
+
+		s.emit(ServiceEvent{State: ServiceRestarting, Pid: pid, Rc: rc, Restarts: restarts + 1})
+		select {
+		case <-time.After(retryDelay(&s.backoff, restarts+1)):
+		case <-s.stop:
+			s.emit(ServiceEvent{State: ServiceStopped, Rc: rc, Restarts: restarts})
+			return
+		}
+	}
+}
+
+// Wait for the process start then for its probes. Only the first start readiness is reported to Start().
+func (s *service) awaitReady(started <-chan error, exited <-chan struct{}, restarts int) {
+	var err error
+	select {
+	case err = <-started:
+	case <-exited:
+		select {
+		case err = <-started:
+		default:
+			err = fmt.Errorf("Service exited before starting: [%s] !", s.name)
+		}
+	}
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), s.readyTimeout)
+		go func() {
+			select {
+			case <-exited:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		for _, probe := range s.probes {
+			if err = probe(ctx); err != nil {
+				break
+			}
+		}
+		cancel()
+		// Output of a long running service must not be kept
+		s.stdout.detach()
+		select {
+		case <-exited:
+			if err != nil {
+				err = fmt.Errorf("Service exited before being ready: [%s] ! Caused by: %w", s.name, err)
+			} else if len(s.probes) > 0 {
+				err = fmt.Errorf("Service exited before being ready: [%s] !", s.name)
+			}
+		default:
+		}
+	}
+	if err == nil {
+		s.emit(ServiceEvent{State: ServiceReady, Pid: s.Pid(), Restarts: restarts})
+	}
+	if restarts == 0 {
+		s.ready <- err
+	}
+}
+
+func (s *service) setPid(pid int) (previous int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous = s.pid
+	s.pid = pid
+	if pid > 0 && s.stopping {
+		// Stop requested while starting
+		_ = syscall.Kill(-pid, s.termSignal())
+	}
+	return
+}
+
+// Pid of the running process, or 0 if not running.
+func (s *service) Pid() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pid
+}
+
+// Termination signal of the exec config, else of the config inherited from sequences.
+func (s *service) termSignal() syscall.Signal {
+	if s.exec.config.termSignal != 0 {
+		return s.exec.config.termSignal
+	}
+	if fallback := s.exec.fallbackConfig; fallback != nil && fallback.termSignal != 0 {
+		return fallback.termSignal
+	}
+	return defaultTermSignal
+}
+
+// Signal send sig to the process group of the running process.
+func (s *service) Signal(sig syscall.Signal) error {
+	pid := s.Pid()
+	if pid == 0 {
+		return fmt.Errorf("Service not running: [%s] !", s.name)
+	}
+	return syscall.Kill(-pid, sig)
+}
+
+// Stop disable restarts, send the termination signal to the process group, then SIGKILL if still running after grace period.
+func (s *service) Stop(grace time.Duration) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	if !s.stopping {
+		s.stopping = true
+		close(s.stop)
+	}
+	pid := s.pid
+	s.mu.Unlock()
+
+	if grace == 0 {
+		grace = defaultTermGrace
+	}
+	if pid > 0 {
+		_ = syscall.Kill(-pid, s.termSignal())
+	}
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(grace):
+	}
+	if pid := s.Pid(); pid > 0 {
+		_ = syscall.Kill(-pid, syscall.SIGKILL)
+	}
+	<-s.done
+	return nil
+}
+
+// Wait for the supervision to end: the process exited without restart or was stopped. Return the last result code.
+func (s *service) Wait(timeout time.Duration) (int, error) {
+	select {
+	case <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.rc, s.err
+	case <-time.After(timeout):
+		return -1, errorz.Timeoutf(timeout, "waiting service end: [%s]", s.name)
+	}
+}
+
+// Done is closed once the supervision ended.
+func (s *service) Done() <-chan struct{} {
+	return s.done
+}
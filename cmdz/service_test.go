@@ -0,0 +1,188 @@
+package cmdz
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/errorz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func states(events []ServiceEvent) (s []ServiceState) {
+	for _, e := range events {
+		s = append(s, e.State)
+	}
+	return
+}
+
+func TestService_ReadyOnOutput(t *testing.T) {
+	stdout := &strings.Builder{}
+	e := Sh("trap 'echo bye; exit 0' TERM; sleep 0.05; echo listening; while true; do sleep 0.01; done")
+	e.SetStdout(stdout)
+	s := Service(e).ReadyOnOutput("listen")
+	start := time.Now()
+	require.NoError(t, s.Start())
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Greater(t, s.Pid(), 0)
+
+	require.NoError(t, s.Stop(time.Second))
+	assert.Equal(t, 0, s.Pid())
+	rc, err := s.Wait(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []ServiceState{ServiceStarting, ServiceReady, ServiceExited, ServiceStopped}, states(s.Events()))
+	assert.Equal(t, "listening\nbye\n", e.StdoutRecord())
+	assert.Equal(t, stdout, e.Stdout())
+}
+
+func TestService_ReadyOnPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	// No server listening: probe times out and the process is stopped
+	s := Service(Cmd("sleep", "10")).ReadyOnPort(addr).ReadyTimeout(100 * time.Millisecond)
+	start := time.Now()
+	err = s.Start()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, ServiceStopped, s.Events()[len(s.Events())-1].State)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l, err := net.Listen("tcp", addr)
+		if err == nil {
+			time.Sleep(time.Second)
+			l.Close()
+		}
+	}()
+	s = Service(Cmd("sleep", "10")).ReadyOnPort(addr)
+	require.NoError(t, s.Start())
+	assert.NoError(t, s.Stop(time.Second))
+}
+
+func TestService_ReadyOnFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ready")
+	s := Service(Sh(fmt.Sprintf("sleep 0.05; touch %s; sleep 10", path))).ReadyOnFile(path)
+	require.NoError(t, s.Start())
+	assert.FileExists(t, path)
+
+	// Stubborn process is killed after grace period
+	require.NoError(t, s.Signal(syscall.SIGSTOP))
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	require.NoError(t, s.Stop(100*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestService_ExitedBeforeReady(t *testing.T) {
+	s := Service(Sh("exit 3")).ReadyOnOutput("never")
+	err := s.Start()
+	assert.ErrorContains(t, err, "exited")
+	rc, err := s.Wait(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rc)
+	assert.Equal(t, []ServiceState{ServiceStarting, ServiceExited, ServiceFailed}, states(s.Events()))
+
+	err = Service(Cmd("notExistingBinary")).Start()
+	assert.Error(t, err)
+}
+
+func TestService_Restart(t *testing.T) {
+	var restarts []int
+	s := Service(Sh("sleep 0.01; exit 2")).
+		Restart(RestartOnFailure, 3, 10*time.Millisecond).
+		Backoff(2, time.Second, 0).
+		OnEvent(func(e ServiceEvent) {
+			if e.State == ServiceRestarting {
+				restarts = append(restarts, e.Restarts)
+			}
+		})
+	start := time.Now()
+	require.NoError(t, s.Start())
+	rc, err := s.Wait(2 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rc)
+	assert.Equal(t, []int{1, 2, 3}, restarts)
+	// 10 + 20 + 40 ms of backoff
+	assert.GreaterOrEqual(t, time.Since(start), 70*time.Millisecond)
+	events := s.Events()
+	assert.Equal(t, ServiceFailed, events[len(events)-1].State)
+
+	// Successful exit is not restarted on failure only
+	s = Service(Cmd("true")).Restart(RestartOnFailure, 0, 10*time.Millisecond)
+	require.NoError(t, s.Start())
+	rc, err = s.Wait(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, []ServiceState{ServiceStarting, ServiceReady, ServiceExited}, states(s.Events()))
+
+	// Stop interrupt restarts
+	s = Service(Cmd("true")).Restart(RestartAlways, 0, time.Second)
+	require.NoError(t, s.Start())
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, s.Stop(time.Second))
+	events = s.Events()
+	assert.Equal(t, ServiceStopped, events[len(events)-1].State)
+}
+
+func TestService_Wait(t *testing.T) {
+	s := Service(Cmd("sleep", "1"))
+	require.NoError(t, s.Start())
+	_, err := s.Wait(50 * time.Millisecond)
+	assert.True(t, errorz.IsTimeout(err))
+	assert.Error(t, s.Start())
+	require.NoError(t, s.Stop(time.Second))
+	assert.Error(t, s.Signal(syscall.SIGTERM))
+}
+
+func TestService_OutputNotBufferedOnceReady(t *testing.T) {
+	e := Sh("echo ready; seq 1 10000; sleep 10")
+	s := Service(e).ReadyOnOutput("ready")
+	require.NoError(t, s.Start())
+	defer s.Stop(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	s.stdout.Lock()
+	buffered := s.stdout.buffer.Len()
+	s.stdout.Unlock()
+	assert.Equal(t, 0, buffered)
+}
+
+func TestService_ReadyOnFile_Exited(t *testing.T) {
+	// Probe is interrupted when the process exits without waiting for the ready timeout
+	s := Service(Sh("sleep 0.05")).ReadyOnFile(filepath.Join(t.TempDir(), "never"))
+	start := time.Now()
+	err := s.Start()
+	assert.ErrorContains(t, err, "never appeared")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestService_ReadyOnFile_StatError(t *testing.T) {
+	// Stat errors other than not existing fail the probe without waiting for the ready timeout
+	notDir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0644))
+	s := Service(Sh("sleep 10")).ReadyOnFile(filepath.Join(notDir, "ready"))
+	start := time.Now()
+	err := s.Start()
+	assert.ErrorContains(t, err, "Unable to check service file")
+	assert.Less(t, time.Since(start), time.Second)
+	require.NoError(t, s.Stop(100*time.Millisecond))
+}
+
+func TestService_TermSignalFallback(t *testing.T) {
+	c := Sh("sleep 10")
+	c.fallback(&config{termSignal: syscall.SIGINT})
+	assert.Equal(t, syscall.SIGINT, Service(c).termSignal())
+
+	c.Termination(syscall.SIGHUP, time.Second)
+	assert.Equal(t, syscall.SIGHUP, Service(c).termSignal())
+}
@@ -0,0 +1,287 @@
+package cmdz
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	andOperator    = "&&"
+	orOperator     = "||"
+	pipeOperator   = "|"
+	serialOperator = ";"
+)
+
+var (
+	safeShellWord = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+	shellName     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Quote an argument for a POSIX shell, so the shell read it back as one word without any expansion.
+func Quote(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	if safeShellWord.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// QuoteAll quote each argument and join them with spaces.
+func QuoteAll(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Unquoted characters of shell features which are not implemented: redirections, expansions, subshells and globs.
+const unsupportedShellChars = "<>$`()*?"
+
+type shellToken struct {
+	value    string
+	operator bool
+}
+
+// Tokenize a command line like a POSIX shell does, without any expansion.
+// Unquoted operators &&, ||, | and ; are returned as operator tokens, an unquoted new line is a ; operator.
+// Unquoted characters of not implemented shell features are errors, they are kept literally in double quotes.
+// Pipeline negation ! and variable assignments before a command are not implemented either.
+func tokenize(line string) (tokens []shellToken, err error) {
+	var word strings.Builder
+	inWord := false
+	// Quoted or escaped words are never assignments
+	quoted := false
+	endWord := func() {
+		if inWord {
+			tokens = append(tokens, shellToken{value: word.String()})
+			word.Reset()
+			inWord = false
+			quoted = false
+		}
+	}
+	commandStart := func() bool {
+		return len(tokens) == 0 || tokens[len(tokens)-1].operator
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == '\n':
+			endWord()
+			// Separate commands unless the line is empty or continued after an operator
+			if len(tokens) > 0 && !tokens[len(tokens)-1].operator {
+				tokens = append(tokens, shellToken{value: serialOperator, operator: true})
+			}
+		case r == '#' && !inWord:
+			// Comment until end of line, the new line still separate commands
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("Unterminated escape at end of command line: %s !", line)
+			}
+			if runes[i] != '\n' {
+				// Escaped new line is a line continuation
+				word.WriteRune(runes[i])
+				inWord = true
+				quoted = true
+			}
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("Unterminated single quote in command line: %s !", line)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			quoted = true
+			i = end
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("Unterminated double quote in command line: %s !", line)
+			}
+			inWord = true
+			quoted = true
+		case r == '&' || r == '|' || r == ';':
+			endWord()
+			op := string(r)
+			if r != ';' && i+1 < len(runes) && runes[i+1] == r {
+				op += string(r)
+				i++
+			}
+			if op == "&" {
+				return nil, fmt.Errorf("Background operator & not supported in command line: %s !", line)
+			}
+			tokens = append(tokens, shellToken{value: op, operator: true})
+		case strings.ContainsRune(unsupportedShellChars, r) || (r == '~' || r == '!') && !inWord:
+			return nil, fmt.Errorf("Unquoted %q not supported in command line: %s !", r, line)
+		case r == '=' && !quoted && commandStart() && shellName.MatchString(word.String()):
+			return nil, fmt.Errorf("Unquoted assignment %s= not supported in command line: %s !", word.String(), line)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endWord()
+	return
+}
+
+// Split a command line into argv like a POSIX shell does, without any expansion.
+func Split(line string) ([]string, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	var argv []string
+	for _, t := range tokens {
+		if t.operator {
+			return nil, fmt.Errorf("Operator %s not supported in simple command: %s !", t.value, line)
+		}
+		argv = append(argv, t.value)
+	}
+	return argv, nil
+}
+
+// Parse a command line into an Executer without using a shell.
+// Operators map onto sequences with shell precedence: | onto Pipe, && onto And, || onto Or and ; onto Serial.
+func Parse(line string) (Executer, error) {
+	tokens, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	p := shellParser{line: line, tokens: tokens}
+	return p.parseList()
+}
+
+// MustParse is like Parse but panics if the command line cannot be parsed.
+func MustParse(line string) Executer {
+	e, err := Parse(line)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+type shellParser struct {
+	line   string
+	tokens []shellToken
+	pos    int
+}
+
+func (p *shellParser) peekOperator() string {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].operator {
+		return p.tokens[p.pos].value
+	}
+	return ""
+}
+
+// list: andOr (; andOr)* [;]
+func (p *shellParser) parseList() (Executer, error) {
+	var execs []Executer
+	for p.pos < len(p.tokens) {
+		e, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		execs = append(execs, e)
+		if p.peekOperator() == serialOperator {
+			p.pos++
+		}
+	}
+	switch len(execs) {
+	case 0:
+		return nil, fmt.Errorf("Empty command line !")
+	case 1:
+		return execs[0], nil
+	}
+	return Serial(execs...), nil
+}
+
+// andOr: pipeline ((&& | ||) pipeline)* left associative, successive same operators are flattened.
+func (p *shellParser) parseAndOr() (Executer, error) {
+	first, err := p.parsePipeline()
+	if err != nil {
+		return nil, err
+	}
+	execs := []Executer{first}
+	current := ""
+	group := func() Executer {
+		if len(execs) == 1 {
+			return execs[0]
+		}
+		if current == andOperator {
+			return And(execs...)
+		}
+		return Or(execs...)
+	}
+	for {
+		op := p.peekOperator()
+		if op != andOperator && op != orOperator {
+			return group(), nil
+		}
+		p.pos++
+		next, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		if current != "" && op != current {
+			execs = []Executer{group()}
+		}
+		current = op
+		execs = append(execs, next)
+	}
+}
+
+// pipeline: command (| command)*
+func (p *shellParser) parsePipeline() (Executer, error) {
+	c, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator() == pipeOperator {
+		p.pos++
+		next, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		c = c.Pipe(next)
+	}
+	return c, nil
+}
+
+func (p *shellParser) parseCommand() (*cmdz, error) {
+	var argv []string
+	for p.pos < len(p.tokens) && !p.tokens[p.pos].operator {
+		argv = append(argv, p.tokens[p.pos].value)
+		p.pos++
+	}
+	if len(argv) == 0 {
+		op := "end of line"
+		if p.pos < len(p.tokens) {
+			op = p.tokens[p.pos].value
+		}
+		return nil, fmt.Errorf("Missing command before %s in command line: %s !", op, p.line)
+	}
+	return Cmd(argv...), nil
+}
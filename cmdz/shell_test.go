@@ -0,0 +1,169 @@
+package cmdz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "''", Quote(""))
+	assert.Equal(t, "foo", Quote("foo"))
+	assert.Equal(t, "/usr/bin/git", Quote("/usr/bin/git"))
+	assert.Equal(t, "--opt=a,b:c", Quote("--opt=a,b:c"))
+	assert.Equal(t, "'a b'", Quote("a b"))
+	assert.Equal(t, "'$HOME'", Quote("$HOME"))
+	assert.Equal(t, `'it'\''s'`, Quote("it's"))
+	assert.Equal(t, "'a;b'", Quote("a;b"))
+	assert.Equal(t, "git commit -m 'a b'", QuoteAll("git", "commit", "-m", "a b"))
+}
+
+func TestSplit(t *testing.T) {
+	argv, err := Split("git commit -m 'a b'")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "commit", "-m", "a b"}, argv)
+
+	argv, err = Split(`echo "a \"b\" \$c \d" e\ f ''  "" g#h # comment`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", `a "b" $c \d`, "e f", "", "", "g#h"}, argv)
+
+	argv, err = Split("echo a\\\nb  \t c")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "ab", "c"}, argv)
+
+	argv, err = Split("   ")
+	require.NoError(t, err)
+	assert.Empty(t, argv)
+
+	_, err = Split("echo 'foo")
+	assert.Error(t, err)
+	_, err = Split(`echo "foo`)
+	assert.Error(t, err)
+	_, err = Split(`echo foo\`)
+	assert.Error(t, err)
+	_, err = Split("echo foo && echo bar")
+	assert.Error(t, err)
+}
+
+func TestSplit_QuoteRoundTrip(t *testing.T) {
+	args := []string{"echo", "", "a b", "it's", `"x"`, "$y", "\\z", "a\nb", "ü"}
+	argv, err := Split(QuoteAll(args...))
+	require.NoError(t, err)
+	assert.Equal(t, args, argv)
+
+	c := Cmd("echo", "a b", "it's")
+	argv, err = Split(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", "a b", "it's"}, argv)
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse("echo 'a b'")
+	require.NoError(t, err)
+	assert.IsType(t, &cmdz{}, e)
+	assert.Equal(t, "echo 'a b'", e.String())
+
+	e, err = Parse("echo foo | tr o 0")
+	require.NoError(t, err)
+	o, err := Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "f00\n", o)
+
+	e, err = Parse("echo foo | tr o 0 | tr f F")
+	require.NoError(t, err)
+	o, err = Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "F00\n", o)
+
+	e, err = Parse("true && echo foo && echo bar")
+	require.NoError(t, err)
+	assert.IsType(t, &andSeq{}, e)
+	o, err = Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", o)
+
+	e, err = Parse("false || echo foo")
+	require.NoError(t, err)
+	assert.IsType(t, &orSeq{}, e)
+	o, err = Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n", o)
+
+	e, err = Parse("echo foo; echo bar;")
+	require.NoError(t, err)
+	assert.IsType(t, &serialSeq{}, e)
+	o, err = Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", o)
+}
+
+func TestParse_Precedence(t *testing.T) {
+	// a && b || c is (a && b) || c
+	e, err := Parse("false && echo foo || echo bar")
+	require.NoError(t, err)
+	require.IsType(t, &orSeq{}, e)
+	o, err := Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "bar\n", o)
+
+	// a || b && c is (a || b) && c
+	e, err = Parse("echo foo || echo bar && echo baz")
+	require.NoError(t, err)
+	require.IsType(t, &andSeq{}, e)
+	o, err = Outputted(e).OutputString()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbaz\n", o)
+
+	// ; has the lowest precedence, pipe the highest
+	e, err = Parse("false && echo foo ; echo bar | tr a A")
+	require.NoError(t, err)
+	require.IsType(t, &serialSeq{}, e)
+	rc, err := e.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "bAr\n", e.StdoutRecord())
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, line := range []string{"", " ; ", "echo foo &&", "| echo foo", "echo foo || || echo bar", "echo foo & echo bar", "echo 'foo"} {
+		_, err := Parse(line)
+		assert.Error(t, err, "parsing %q", line)
+	}
+	assert.Panics(t, func() { MustParse("echo 'foo") })
+}
+
+func TestParse_Unsupported(t *testing.T) {
+	for _, line := range []string{"echo foo > out", "cat < in", "echo $HOME", "echo `id`", "(echo foo)", "ls *.go", "ls fo?", "cd ~",
+		"FOO=bar env", "echo foo && FOO=bar env", "! false", "true | ! false", "echo !foo"} {
+		_, err := Parse(line)
+		assert.ErrorContains(t, err, "not supported", "parsing %q", line)
+		_, err = Split(line)
+		assert.Error(t, err, "splitting %q", line)
+	}
+
+	// Quoted or escaped they are literal
+	argv, err := Split(`echo '>' "<" \$HOME "*" a~b '!' b!`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", ">", "<", "$HOME", "*", "a~b", "!", "b!"}, argv)
+
+	// Assignments are only unsupported before a command
+	argv, err = Split(`env FOO=bar 'BAR=baz' cmd`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env", "FOO=bar", "BAR=baz", "cmd"}, argv)
+	argv, err = Split(`'FOO=bar' cmd`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FOO=bar", "cmd"}, argv)
+	argv, err = Split(`a-b=c =foo`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a-b=c", "=foo"}, argv)
+}
+
+func TestParse_NewLine(t *testing.T) {
+	e, err := Parse("echo foo # comment\n\necho bar &&\necho baz\n")
+	require.NoError(t, err)
+	assert.Equal(t, "echo foo\necho bar && echo baz", e.String())
+
+	_, err = Split("echo foo\necho bar")
+	assert.Error(t, err)
+}
@@ -1,10 +1,14 @@
 package cmdz
 
 import (
+	"context"
 	"io"
+	"os"
+	"syscall"
 	"time"
 
 	"github.com/mxbossard/utilz/inoutz"
+	"github.com/mxbossard/utilz/printz"
 	"github.com/mxbossard/utilz/promiz"
 )
 
@@ -47,8 +51,28 @@ type (
 	Configurer[T any] interface {
 		ErrorOnFailure(bool) T
 		Retries(count, delayInMs int) T
+		Backoff(factor float64, maxDelay time.Duration, jitter float64) T
+		RetryTimeout(total time.Duration) T
+		RetryIf(predicate RetryPredicate) T
 		Timeout(duration time.Duration) T
+		Termination(signal syscall.Signal, grace time.Duration) T
+		Context(ctx context.Context) T
+		Dir(path string) T
+		Nice(n int) T
+		Umask(mask os.FileMode) T
+		Limits(limits ResourceLimits) T
+		InheritEnv(allowed ...string) T
+		CleanEnv() T
+		Secrets(values ...string) T
+		Hooks(hooks ...Hooks) T
+		DryRun(printer printz.Printer) T
+		DryRunRc(rc int) T
+		RecordLimit(maxBytes int) T
+		SpillRecords() T
 		CombinedOutputs() T
+		Mock(m *Mock) T
+		Record(r *Recording) T
+		InProcess(fn ProcessFunc) T
 		AddEnv(key, value string) T
 		AddEnviron(environ ...string) T
 		AddArgs(args ...string) T
@@ -58,6 +82,8 @@ type (
 		StdinRecord() string
 		StdoutRecord() string
 		StderrRecord() string
+		StdoutFile() string
+		StderrFile() string
 	}
 
 	Reporter interface {
@@ -69,6 +95,8 @@ type (
 		init()
 		reset()
 		fallback(*config)
+		// Run with ctx scoped to this run only, used if no ctx is configured.
+		blockRunCtx(ctx context.Context) (int, error)
 
 		BlockRun() (int, error)
 		AsyncRun() *execPromise
@@ -100,6 +128,7 @@ type (
 		Runner
 
 		getConfig() config
+		report() ExecutionReport
 
 		//Pipe(Executer) Executer
 		//PipeFail(Executer) Executer
@@ -115,6 +144,13 @@ type (
 	OutProcesser0      = func(int, []byte, []byte, error) (int, []byte, []byte, error)
 	OutStringProcesser = func(int, string, string) (string, error)
 
+	// Decide if a failed attempt should be retried given its result code and outputs
+	RetryPredicate = func(rc int, stdout, stderr []byte) bool
+
+	// Execute a command in process instead of starting it, returning its result code.
+	// ctx is cancelled on timeout or cancellation of the executer context.
+	ProcessFunc = func(ctx context.Context, c *Vcmd) int
+
 	Outputer interface {
 		Configurer[Outputer]
 		Recorder
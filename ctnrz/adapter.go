@@ -0,0 +1,248 @@
+package ctnrz
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mxbossard/utilz/utilz"
+)
+
+type Capability string
+
+const (
+	NERDCTL = Provider("NERDCTL")
+
+	// Engine manage pods
+	PODS = Capability("PODS")
+	// Engine support --userns keep-id
+	KEEP_ID_USERNS = Capability("KEEP_ID_USERNS")
+	// Engine support --rm with detached containers
+	DETACHED_RM = Capability("DETACHED_RM")
+)
+
+// Providers detected in PATH by order of preference.
+var detectedProviders = []Provider{PODMAN, DOCKER, NERDCTL}
+
+// adapter handle the differences between engine CLIs.
+type adapter interface {
+	provider() Provider
+	// Binary searched in PATH
+	binaryName() string
+	// Flags added to run and create commands
+	createFlags() []string
+	// ps --format value printing JSON
+	psFormat() string
+	supports(capability Capability) bool
+}
+
+var rootless = func() bool {
+	return os.Geteuid() != 0
+}
+
+type podmanAdapter struct{}
+
+func (podmanAdapter) provider() Provider {
+	return PODMAN
+}
+
+func (podmanAdapter) binaryName() string {
+	return "podman"
+}
+
+func (a podmanAdapter) createFlags() []string {
+	// Keep host user id in rootless containers
+	if a.supports(KEEP_ID_USERNS) {
+		return []string{"--userns", "keep-id"}
+	}
+	return nil
+}
+
+func (podmanAdapter) psFormat() string {
+	return "json"
+}
+
+func (podmanAdapter) supports(capability Capability) bool {
+	switch capability {
+	case PODS, DETACHED_RM:
+		return true
+	case KEEP_ID_USERNS:
+		// keep-id is only supported in rootless mode
+		return rootless()
+	}
+	return false
+}
+
+type dockerAdapter struct{}
+
+func (dockerAdapter) provider() Provider {
+	return DOCKER
+}
+
+func (dockerAdapter) binaryName() string {
+	return "docker"
+}
+
+func (dockerAdapter) createFlags() []string {
+	return nil
+}
+
+func (dockerAdapter) psFormat() string {
+	return "{{json .}}"
+}
+
+func (dockerAdapter) supports(capability Capability) bool {
+	return capability == DETACHED_RM
+}
+
+type nerdctlAdapter struct{}
+
+func (nerdctlAdapter) provider() Provider {
+	return NERDCTL
+}
+
+func (nerdctlAdapter) binaryName() string {
+	return "nerdctl"
+}
+
+func (nerdctlAdapter) createFlags() []string {
+	return nil
+}
+
+func (nerdctlAdapter) psFormat() string {
+	return "{{json .}}"
+}
+
+func (nerdctlAdapter) supports(capability Capability) bool {
+	return false
+}
+
+type fakeAdapter struct{}
+
+func (fakeAdapter) provider() Provider {
+	return FAKE
+}
+
+func (fakeAdapter) binaryName() string {
+	return FAKE_ENGINE
+}
+
+func (fakeAdapter) createFlags() []string {
+	return nil
+}
+
+func (fakeAdapter) psFormat() string {
+	return "json"
+}
+
+func (fakeAdapter) supports(capability Capability) bool {
+	return capability == DETACHED_RM
+}
+
+func providerAdapter(provider Provider) (adapter, error) {
+	switch provider {
+	case PODMAN:
+		return podmanAdapter{}, nil
+	case DOCKER:
+		return dockerAdapter{}, nil
+	case NERDCTL:
+		return nerdctlAdapter{}, nil
+	case FAKE:
+		return fakeAdapter{}, nil
+	}
+	return nil, fmt.Errorf("Unknown container engine provider: %s !", provider)
+}
+
+// Lazily detected engine version, shared by copies of an engine.
+type engineVersion struct {
+	once    sync.Once
+	version string
+	err     error
+}
+
+var versionRegexp = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+func (v *engineVersion) get(e engine) (string, error) {
+	if v == nil {
+		return detectVersion(e)
+	}
+	v.once.Do(func() {
+		v.version, v.err = detectVersion(e)
+	})
+	return v.version, v.err
+}
+
+// Parse version from "<binary> --version" output, e.g. "Docker version 24.0.7, build afdd53b".
+func detectVersion(e engine) (string, error) {
+	c := e.command(e.binary, "--version")
+	_, err := c.BlockRun()
+	if err != nil {
+		return "", err
+	}
+	version := versionRegexp.FindString(c.StdoutRecord())
+	if version == "" {
+		return "", fmt.Errorf("Unable to detect %s version in: [%s] !", e.Provider(), strings.TrimSpace(c.StdoutRecord()))
+	}
+	return version, nil
+}
+
+// NewEngine build an engine of provider. If binary is empty it is searched in PATH.
+// The FAKE provider return the default fake engine.
+func NewEngine(provider Provider, binary string) (Engine, error) {
+	a, err := providerAdapter(provider)
+	if err != nil {
+		return nil, err
+	}
+	if provider == FAKE {
+		return defaultFake.Engine(), nil
+	}
+	if binary == "" {
+		binary, err = exec.LookPath(a.binaryName())
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find %s in PATH ! Caused by: %w", a.binaryName(), err)
+		}
+	}
+	return engine{adapter: a, binary: binary, version: &engineVersion{}}, nil
+}
+
+// Detect the engine configured by CONTAINER_ENGINE env var: a provider name (podman, docker, nerdctl, fake)
+// or the path of an engine binary. If not configured, search podman then docker then nerdctl in PATH.
+func Detect() (Engine, error) {
+	if ok, value := utilz.EnvValue(CONTAINER_ENGINE_ENV_KEY); ok && value != "" {
+		return configuredEngine(value)
+	}
+	for _, provider := range detectedProviders {
+		if e, err := NewEngine(provider, ""); err == nil {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("No container engine found in PATH ! You must install podman, docker or nerdctl.")
+}
+
+func DetectOrPanic() Engine {
+	e, err := Detect()
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func configuredEngine(value string) (Engine, error) {
+	name := strings.ToUpper(value)
+	if _, err := providerAdapter(Provider(name)); err == nil {
+		return NewEngine(Provider(name), "")
+	}
+	// Engine binary path: provider guessed from its name
+	base := filepath.Base(value)
+	for _, provider := range detectedProviders {
+		a, _ := providerAdapter(provider)
+		if strings.Contains(base, a.binaryName()) {
+			return NewEngine(provider, value)
+		}
+	}
+	return nil, fmt.Errorf("Unable to select container engine from %s=%s !", CONTAINER_ENGINE_ENV_KEY, value)
+}
@@ -0,0 +1,125 @@
+package ctnrz
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdapters(t *testing.T) {
+	defer func(r func() bool) { rootless = r }(rootless)
+
+	rootless = func() bool { return true }
+	podman := podmanAdapter{}
+	assert.True(t, podman.supports(PODS))
+	assert.True(t, podman.supports(KEEP_ID_USERNS))
+	assert.Equal(t, []string{"--userns", "keep-id"}, podman.createFlags())
+	assert.Equal(t, "json", podman.psFormat())
+
+	rootless = func() bool { return false }
+	assert.False(t, podman.supports(KEEP_ID_USERNS))
+	assert.Empty(t, podman.createFlags())
+
+	docker := dockerAdapter{}
+	assert.False(t, docker.supports(PODS))
+	assert.False(t, docker.supports(KEEP_ID_USERNS))
+	assert.True(t, docker.supports(DETACHED_RM))
+	assert.Equal(t, "{{json .}}", docker.psFormat())
+
+	nerdctl := nerdctlAdapter{}
+	assert.False(t, nerdctl.supports(DETACHED_RM))
+	assert.Equal(t, "{{json .}}", nerdctl.psFormat())
+}
+
+func TestRunDetachedRm(t *testing.T) {
+	docker := engine{adapter: dockerAdapter{}, binary: "docker"}
+	assert.Equal(t, "docker run --name foo --rm -d alpine", docker.Container("foo").Run("alpine").Rm().Detach().Executer().String())
+	nerdctl := engine{adapter: nerdctlAdapter{}, binary: "nerdctl"}
+	assert.Equal(t, "nerdctl run --name foo -d alpine", nerdctl.Container("foo").Run("alpine").Rm().Detach().Executer().String())
+	assert.Equal(t, "nerdctl run --name foo --rm alpine", nerdctl.Container("foo").Run("alpine").Rm().Executer().String())
+}
+
+// Put fake engine binaries in an empty PATH.
+func fakePath(t *testing.T, binaries ...string) string {
+	dir := t.TempDir()
+	for _, binary := range binaries {
+		path := filepath.Join(dir, binary)
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho \""+binary+" version 1.2.3\"\n"), 0755))
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func TestNewEngine(t *testing.T) {
+	dir := fakePath(t, "docker")
+	e, err := NewEngine(DOCKER, "")
+	require.NoError(t, err)
+	assert.Equal(t, DOCKER, e.Provider())
+	assert.Equal(t, filepath.Join(dir, "docker"), e.Binary())
+
+	e, err = NewEngine(PODMAN, "/opt/bin/podman")
+	require.NoError(t, err)
+	assert.Equal(t, PODMAN, e.Provider())
+	assert.Equal(t, "/opt/bin/podman", e.Binary())
+
+	_, err = NewEngine(PODMAN, "")
+	assert.Error(t, err)
+	_, err = NewEngine(Provider("FOO"), "")
+	assert.Error(t, err)
+
+	e, err = NewEngine(FAKE, "")
+	require.NoError(t, err)
+	assert.Same(t, defaultFake, e.(engine).fake)
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv(CONTAINER_ENGINE_ENV_KEY, "")
+	fakePath(t)
+	_, err := Detect()
+	assert.Error(t, err)
+
+	// podman preferred to docker
+	dir := fakePath(t, "docker", "nerdctl")
+	e, err := Detect()
+	require.NoError(t, err)
+	assert.Equal(t, DOCKER, e.Provider())
+	fakePath(t, "podman", "docker")
+	e, err = Detect()
+	require.NoError(t, err)
+	assert.Equal(t, PODMAN, e.Provider())
+
+	// Overriden by env
+	t.Setenv("PATH", dir)
+	t.Setenv(CONTAINER_ENGINE_ENV_KEY, "nerdctl")
+	e, err = Detect()
+	require.NoError(t, err)
+	assert.Equal(t, NERDCTL, e.Provider())
+	t.Setenv(CONTAINER_ENGINE_ENV_KEY, "/usr/local/bin/podman")
+	e, err = Detect()
+	require.NoError(t, err)
+	assert.Equal(t, PODMAN, e.Provider())
+	assert.Equal(t, "/usr/local/bin/podman", e.Binary())
+	t.Setenv(CONTAINER_ENGINE_ENV_KEY, "foo")
+	_, err = Detect()
+	assert.Error(t, err)
+	assert.Panics(t, func() { DetectOrPanic() })
+}
+
+func TestVersion(t *testing.T) {
+	fakePath(t, "docker")
+	e, err := NewEngine(DOCKER, "")
+	require.NoError(t, err)
+	version, err := e.Version()
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", version)
+
+	version, err = NewFake().Engine().Version()
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", version)
+
+	_, err = scriptEngine(t, "no version").Version()
+	assert.Error(t, err)
+}
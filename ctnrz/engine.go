@@ -1,12 +1,17 @@
 package ctnrz
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mxbossard/utilz/cmdz"
+	"github.com/mxbossard/utilz/collectionz"
 	"github.com/mxbossard/utilz/utilz"
 )
 
@@ -23,6 +28,7 @@ const (
 
 	PODMAN = Provider("PODMAN")
 	DOCKER = Provider("DOCKER")
+	FAKE   = Provider("FAKE")
 )
 
 /*
@@ -30,8 +36,9 @@ const (
 		container
 	}
 */
-type runner struct {
-	container
+// Options of a container shared by run and create. Setters return the builder B for chaining.
+type createOptions[B any] struct {
+	builder B
 
 	image      string
 	cmdAndArgs []string
@@ -39,69 +46,228 @@ type runner struct {
 	interactive  bool
 	tty          bool
 	remove       bool
-	detach       bool
 	privileged   bool
+	init         bool
 	user         string
 	userns       string
+	workdir      string
+	hostname     string
+	restart      string
 	cpuLimit     float32
 	memLimitInMb int
 	volumes      []string
+	binds        []string
+	tmpfs        []string
+	ports        []string
+	networks     []string
+	labels       []string
 	envArgs      []string
 	entrypoint   string
+	healthcheck  *Healthcheck
+}
+
+type runner struct {
+	container
+	createOptions[*runner]
+
+	detach bool
+}
+
+// Healthcheck of a container. Zero durations keep engine defaults.
+type Healthcheck struct {
+	Cmd         string
+	Interval    time.Duration
+	Timeout     time.Duration
+	StartPeriod time.Duration
+	Retries     int
+}
+
+var (
+	containerNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	restartRegexp       = regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`)
+)
+
+// Validate return all errors of runner options joined.
+func (c *runner) Validate() error {
+	return c.createOptions.validate("run", c.name)
+}
+
+// All errors of the options of the container named name joined, nil if valid.
+func (c *createOptions[B]) validate(command, name string) error {
+	var errs []error
+	if c.image == "" {
+		errs = append(errs, fmt.Errorf("missing image"))
+	}
+	if !containerNameRegexp.MatchString(name) {
+		errs = append(errs, fmt.Errorf("bad container name: %q", name))
+	}
+	if c.restart != "" && !restartRegexp.MatchString(c.restart) {
+		errs = append(errs, fmt.Errorf("bad restart policy: %q", c.restart))
+	} else if c.remove && c.restart != "" && c.restart != "no" {
+		errs = append(errs, fmt.Errorf("restart policy %q conflict with rm", c.restart))
+	}
+	if c.cpuLimit < 0 {
+		errs = append(errs, fmt.Errorf("negative cpu limit: %v", c.cpuLimit))
+	}
+	if c.memLimitInMb < 0 {
+		errs = append(errs, fmt.Errorf("negative memory limit: %d", c.memLimitInMb))
+	}
+	if c.workdir != "" && !path.IsAbs(c.workdir) {
+		errs = append(errs, fmt.Errorf("workdir is not absolute: %q", c.workdir))
+	}
+	for _, vol := range c.volumes {
+		parts := strings.Split(vol, ":")
+		// Anonymous volume is a single container path
+		anonymous := len(parts) == 1 && path.IsAbs(vol)
+		if !anonymous && (len(parts) < 2 || parts[0] == "" || !path.IsAbs(parts[1])) {
+			errs = append(errs, fmt.Errorf("bad volume: %q", vol))
+		}
+	}
+	for _, tmpfs := range c.tmpfs {
+		if dir, _, _ := strings.Cut(tmpfs, ":"); !path.IsAbs(dir) {
+			errs = append(errs, fmt.Errorf("tmpfs path is not absolute: %q", dir))
+		}
+	}
+	for _, port := range c.ports {
+		if _, err := parsePublish(port); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, entry := range append(append([]string(nil), c.envArgs...), c.labels...) {
+		if key, _, _ := strings.Cut(entry, "="); key == "" {
+			errs = append(errs, fmt.Errorf("missing key in: %q", entry))
+		}
+	}
+	if h := c.healthcheck; h != nil {
+		if h.Cmd == "" {
+			errs = append(errs, fmt.Errorf("missing healthcheck command"))
+		}
+		if h.Interval < 0 || h.Timeout < 0 || h.StartPeriod < 0 || h.Retries < 0 {
+			errs = append(errs, fmt.Errorf("negative healthcheck durations or retries"))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Invalid %s of container %s ! Caused by: %w", command, name, errors.Join(errs...))
+	}
+	return nil
 }
 
 func (c *runner) Executer() cmdz.Executer {
-	params := []string{c.binary, "run"}
-	if c.name != "" {
-		params = append(params, "--name", c.name)
+	return c.createOptions.executer(c.container, "run", c.detach, c.Validate())
+}
+
+// Executer of the engine command. Its runs fail with invalid without executing the engine, see Validate().
+func (c *createOptions[B]) executer(ctnr container, command string, detach bool, invalid error) cmdz.Executer {
+	params := []string{ctnr.binary, command}
+	params = append(params, c.params(ctnr, detach)...)
+
+	var e cmdz.Executer
+	if invalid != nil {
+		e = ctnr.invalidCommand(invalid, params...)
+	} else {
+		e = ctnr.command(params...)
+	}
+	if ctnr.timeout != nil {
+		e.Timeout(*ctnr.timeout)
+	}
+	return e
+}
+
+// Engine params of the options: name, flags, then image and command. Detach is a run only option.
+func (c *createOptions[B]) params(ctnr container, detach bool) (params []string) {
+	if ctnr.name != "" {
+		params = append(params, "--name", ctnr.name)
+	}
+	if c.interactive {
+		params = append(params, "-i")
+	}
+	if c.tty {
+		params = append(params, "-t")
 	}
-	if c.remove {
+	// Some engines cannot remove detached containers
+	if c.remove && (!detach || ctnr.Supports(DETACHED_RM)) {
 		params = append(params, "--rm")
 	}
-	if c.detach {
+	if detach {
 		params = append(params, "-d")
 	}
+	if c.privileged {
+		params = append(params, "--privileged")
+	}
+	if c.init {
+		params = append(params, "--init")
+	}
 	if c.entrypoint != "" {
 		params = append(params, "--entrypoint", c.entrypoint)
 	}
 	if c.user != "" {
 		params = append(params, "-u", c.user)
 	}
+	if c.userns != "" {
+		params = append(params, "--userns", c.userns)
+	} else {
+		// Engine default userns
+		params = append(params, ctnr.adapter.createFlags()...)
+	}
+	if c.workdir != "" {
+		params = append(params, "-w", c.workdir)
+	}
+	if c.hostname != "" {
+		params = append(params, "--hostname", c.hostname)
+	}
+	if c.restart != "" {
+		params = append(params, "--restart", c.restart)
+	}
+	if c.cpuLimit > 0 {
+		params = append(params, "--cpus", strconv.FormatFloat(float64(c.cpuLimit), 'f', -1, 32))
+	}
+	if c.memLimitInMb > 0 {
+		params = append(params, "--memory", fmt.Sprintf("%dm", c.memLimitInMb))
+	}
 	for _, arg := range c.volumes {
 		params = append(params, "-v", arg)
 	}
+	for _, arg := range c.binds {
+		params = append(params, "--mount", arg)
+	}
+	for _, arg := range c.tmpfs {
+		params = append(params, "--tmpfs", arg)
+	}
+	for _, arg := range c.ports {
+		params = append(params, "-p", arg)
+	}
+	for _, arg := range c.networks {
+		params = append(params, "--network", arg)
+	}
+	for _, arg := range c.labels {
+		params = append(params, "--label", arg)
+	}
 	for _, envArg := range c.envArgs {
-		var envArg string = "-e=" + envArg
-		params = append(params, envArg)
+		params = append(params, "-e="+envArg)
 	}
-
-	// podman specific
-	if c.provider == PODMAN {
-		// FIXME: always do this for podman ?
-		params = append(params, "--userns", "keep-id")
+	if h := c.healthcheck; h != nil {
+		params = append(params, "--health-cmd", h.Cmd)
+		if h.Interval > 0 {
+			params = append(params, "--health-interval", h.Interval.String())
+		}
+		if h.Timeout > 0 {
+			params = append(params, "--health-timeout", h.Timeout.String())
+		}
+		if h.StartPeriod > 0 {
+			params = append(params, "--health-start-period", h.StartPeriod.String())
+		}
+		if h.Retries > 0 {
+			params = append(params, "--health-retries", strconv.Itoa(h.Retries))
+		}
 	}
 
 	params = append(params, c.image)
 	// Add command args
 	params = append(params, c.cmdAndArgs...)
-
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
-	if c.timeout != nil {
-		e.Timeout(*c.timeout)
-	}
-	return e
-}
-
-func (c *runner) Interactive() *runner {
-	c.interactive = true
-	return c
-}
-
-func (c *runner) Tty() *runner {
-	c.tty = true
-	return c
+	return
 }
 
+// Rm remove the container once stopped. Engines without DETACHED_RM capability keep detached containers.
 func (c *runner) Rm() *runner {
 	c.remove = true
 	return c
@@ -112,38 +278,6 @@ func (c *runner) Detach() *runner {
 	return c
 }
 
-func (c *runner) Privileged() *runner {
-	c.privileged = true
-	return c
-}
-
-func (c *runner) User(user string) *runner {
-	c.user = user
-	return c
-}
-
-func (c *runner) Entrypoint(entrypoint string) *runner {
-	c.entrypoint = entrypoint
-	return c
-}
-
-func (c *runner) AddEnvs(envs ...string) *runner {
-	c.envArgs = append(c.envArgs, envs...)
-	return c
-}
-
-func (c *runner) AddEnvMap(envs map[string]string) *runner {
-	for key, value := range envs {
-		c.AddEnvs(key + "=" + value)
-	}
-	return c
-}
-
-func (c *runner) AddVolumes(vols ...string) *runner {
-	c.volumes = append(c.volumes, vols...)
-	return c
-}
-
 func (c *runner) Timeout(timeout time.Duration) *runner {
 	c.timeout = &timeout
 	return c
@@ -179,7 +313,7 @@ func (c *executer) Executer() cmdz.Executer {
 	params = append(params, c.name)
 	params = append(params, c.cmdAndArgs...)
 
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
@@ -225,18 +359,38 @@ func (c *executer) Timeout(timeout time.Duration) *executer {
 
 type starter struct {
 	container
+
+	attach      bool
+	interactive bool
 }
 
 func (c *starter) Executer() cmdz.Executer {
 	params := []string{c.binary, "start"}
+	if c.attach {
+		params = append(params, "-a")
+	}
+	if c.interactive {
+		params = append(params, "-i")
+	}
 	params = append(params, c.name)
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
 	return e
 }
 
+// Attach container outputs, the execution last until the container stopped.
+func (c *starter) Attach() *starter {
+	c.attach = true
+	return c
+}
+
+func (c *starter) Interactive() *starter {
+	c.interactive = true
+	return c
+}
+
 func (c *starter) Timeout(timeout time.Duration) *starter {
 	c.timeout = &timeout
 	return c
@@ -252,7 +406,7 @@ func (c *stopper) Executer() cmdz.Executer {
 		params = append(params, "--time", fmt.Sprintf("%d", int64(c.timeout.Round(time.Second).Seconds())))
 	}
 	params = append(params, c.name)
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	return e
 }
 
@@ -264,18 +418,77 @@ func (c *stopper) Timeout(timeout time.Duration) *stopper {
 type pser struct {
 	engine
 
+	all       bool
+	filters   []string
+	namesExpr string
+
 	timeout *time.Duration
 }
 
 func (c *pser) Executer() cmdz.Executer {
-	params := []string{c.binary, "ps"}
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	params := []string{c.binary, "ps", "--no-trunc", "--format", c.adapter.psFormat()}
+	if c.all {
+		params = append(params, "-a")
+	}
+	for _, filter := range c.filters {
+		params = append(params, "--filter", filter)
+	}
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
 	return e
 }
 
+// Formatter decode listed containers.
+func (c *pser) Formatter() cmdz.Formatter[[]ContainerSummary] {
+	namesExpr := c.namesExpr
+	return cmdz.Formatted(c.Executer(), func(rc int, stdout, stderr []byte) ([]ContainerSummary, error) {
+		summaries, err := decodePs(rc, stdout, stderr)
+		if err != nil || namesExpr == "" {
+			return summaries, err
+		}
+		// Engines do not agree on name filter semantics, so names are matched here
+		r, err := regexp.Compile(namesExpr)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to filter names ! Caused by: %w", err)
+		}
+		return collectionz.Filter(&summaries, func(s ContainerSummary) bool {
+			return slices.ContainsFunc(s.Names, r.MatchString)
+		}), nil
+	})
+}
+
+// All list stopped containers too.
+func (c *pser) All() *pser {
+	c.all = true
+	return c
+}
+
+// FilterLabel keep containers with a label: "key" or "key=value".
+func (c *pser) FilterLabel(label string) *pser {
+	c.filters = append(c.filters, "label="+label)
+	return c
+}
+
+// FilterStatus keep containers in a status: created, running, paused, exited, ...
+func (c *pser) FilterStatus(status string) *pser {
+	c.filters = append(c.filters, "status="+status)
+	return c
+}
+
+// FilterAncestor keep containers created from an image or its descendants.
+func (c *pser) FilterAncestor(image string) *pser {
+	c.filters = append(c.filters, "ancestor="+image)
+	return c
+}
+
+// FilterName keep containers with a name matching the regexp expr.
+func (c *pser) FilterName(expr string) *pser {
+	c.namesExpr = expr
+	return c
+}
+
 func (c *pser) Timeout(timeout time.Duration) *pser {
 	c.timeout = &timeout
 	return c
@@ -297,7 +510,7 @@ func (c *builder) Executer() cmdz.Executer {
 		params = append(params, "--no-cache")
 	}
 	params = append(params, c.buildCtx)
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
@@ -324,7 +537,7 @@ type puller struct {
 
 func (c *puller) Executer() cmdz.Executer {
 	params := []string{c.binary, "pull", c.image}
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
@@ -346,7 +559,7 @@ type pusher struct {
 
 func (c *pusher) Executer() cmdz.Executer {
 	params := []string{c.binary, "push", c.image}
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
@@ -368,8 +581,8 @@ type tagger struct {
 }
 
 func (c *tagger) Executer() cmdz.Executer {
-	params := []string{c.binary, "tag", c.image}
-	e := cmdz.Cmd(params...).ErrorOnFailure(true)
+	params := []string{c.binary, "tag", c.image, c.tag}
+	e := c.command(params...)
 	if c.timeout != nil {
 		e.Timeout(*c.timeout)
 	}
@@ -381,9 +594,65 @@ func (c *tagger) Timeout(timeout time.Duration) *tagger {
 	return c
 }
 
+// Engine build container commands for a container engine.
+type Engine interface {
+	Provider() Provider
+	Binary() string
+	// Version of the engine client
+	Version() (string, error)
+	Supports(capability Capability) bool
+
+	Container(names ...string) *container
+	Ps() *pser
+	Build(buildCtxDir, tag string) *builder
+	Pull(image string) *puller
+	Push(image string) *pusher
+	Tagger(image, tag string) *tagger
+	// Build a validated runner from a declarative spec
+	RunSpec(spec RunSpec) (*runner, error)
+	RunYaml(input []byte) (*runner, error)
+
+	Status(name string) (Status, error)
+	Exists(name string) (bool, error)
+	IsRunning(name string) (bool, error)
+	IsStopped(name string) (bool, error)
+}
+
 type engine struct {
-	provider Provider
-	binary   string
+	adapter adapter
+	binary  string
+	fake    *Fake
+	version *engineVersion
+}
+
+func (e engine) Provider() Provider {
+	return e.adapter.provider()
+}
+
+func (e engine) Binary() string {
+	return e.binary
+}
+
+func (e engine) Version() (string, error) {
+	return e.version.get(e)
+}
+
+func (e engine) Supports(capability Capability) bool {
+	return e.adapter.supports(capability)
+}
+
+// Build the executer of an engine command, executed in process by fake engines.
+func (e engine) command(params ...string) cmdz.Executer {
+	c := cmdz.Cmd(params...).ErrorOnFailure(true)
+	if e.fake != nil {
+		c.InProcess(e.fake.handle)
+	}
+	return c
+}
+
+// Build the executer of an invalid engine command, failing with err instead of executing.
+func (e engine) invalidCommand(err error, params ...string) cmdz.Executer {
+	return cmdz.Invalid(err, params...).ErrorOnFailure(true)
 }
 
 func (e engine) Container(names ...string) *container {
@@ -403,39 +672,172 @@ func (e engine) Container(names ...string) *container {
 }
 
 func (e engine) Ps() *pser {
-	return &pser{}
+	return &pser{engine: e}
 }
 
 func (e engine) Build(buildCtxDir, tag string) *builder {
-	return &builder{buildCtx: buildCtxDir, tag: tag}
+	return &builder{engine: e, buildCtx: buildCtxDir, tag: tag}
 }
 
 func (e engine) Pull(image string) *puller {
-	return &puller{image: image}
+	return &puller{engine: e, image: image}
 }
 
 func (e engine) Push(image string) *pusher {
-	return &pusher{image: image}
+	return &pusher{engine: e, image: image}
 }
 
 func (e engine) Tagger(image, tag string) *tagger {
-	return &tagger{image: image, tag: tag}
+	return &tagger{engine: e, image: image, tag: tag}
+}
+
+// Status of the container named name. Error if the engine cannot list containers.
+func (e engine) Status(name string) (Status, error) {
+	summaries, err := e.Ps().All().FilterName("^" + regexp.QuoteMeta(name) + "$").Formatter().Format()
+	if err != nil {
+		return "", fmt.Errorf("Unable to get status of container %s ! Caused by: %w", name, err)
+	}
+	if len(summaries) == 0 {
+		return NOT_FOUND, nil
+	}
+	return summaries[0].Status(), nil
+}
+
+func (e engine) Exists(name string) (bool, error) {
+	status, err := e.Status(name)
+	return status != NOT_FOUND && err == nil, err
+}
+
+func (e engine) IsRunning(name string) (bool, error) {
+	status, err := e.Status(name)
+	return status == RUNNING, err
 }
 
-func (e engine) Status(name string) Status {
-	panic("not implemented yet")
+func (e engine) IsStopped(name string) (bool, error) {
+	status, err := e.Status(name)
+	return status == STOPPED, err
 }
 
-func (e engine) Exists(name string) bool {
-	panic("not implemented yet")
+func (c *createOptions[B]) Interactive() B {
+	c.interactive = true
+	return c.builder
 }
 
-func (e engine) IsRunning(name string) bool {
-	panic("not implemented yet")
+func (c *createOptions[B]) Tty() B {
+	c.tty = true
+	return c.builder
 }
 
-func (e engine) IsStopped(name string) bool {
-	panic("not implemented yet")
+func (c *createOptions[B]) Privileged() B {
+	c.privileged = true
+	return c.builder
+}
+
+func (c *createOptions[B]) User(user string) B {
+	c.user = user
+	return c.builder
+}
+
+func (c *createOptions[B]) Entrypoint(entrypoint string) B {
+	c.entrypoint = entrypoint
+	return c.builder
+}
+
+func (c *createOptions[B]) AddEnvs(envs ...string) B {
+	c.envArgs = append(c.envArgs, envs...)
+	return c.builder
+}
+
+func (c *createOptions[B]) AddEnvMap(envs map[string]string) B {
+	for key, value := range envs {
+		c.AddEnvs(key + "=" + value)
+	}
+	return c.builder
+}
+
+func (c *createOptions[B]) AddVolumes(vols ...string) B {
+	c.volumes = append(c.volumes, vols...)
+	return c.builder
+}
+
+func (c *createOptions[B]) Init() B {
+	c.init = true
+	return c.builder
+}
+
+func (c *createOptions[B]) Userns(mode string) B {
+	c.userns = mode
+	return c.builder
+}
+
+func (c *createOptions[B]) Workdir(dir string) B {
+	c.workdir = dir
+	return c.builder
+}
+
+func (c *createOptions[B]) Hostname(hostname string) B {
+	c.hostname = hostname
+	return c.builder
+}
+
+// Restart policy: no, always, unless-stopped or on-failure[:max-retries].
+func (c *createOptions[B]) Restart(policy string) B {
+	c.restart = policy
+	return c.builder
+}
+
+func (c *createOptions[B]) CpuLimit(cpus float32) B {
+	c.cpuLimit = cpus
+	return c.builder
+}
+
+func (c *createOptions[B]) MemLimitInMb(mb int) B {
+	c.memLimitInMb = mb
+	return c.builder
+}
+
+// Bind mount source host path on target container path, with mount options like readonly or bind-propagation=rslave.
+func (c *createOptions[B]) Bind(source, target string, options ...string) B {
+	mount := strings.Join(append([]string{"type=bind", "source=" + source, "target=" + target}, options...), ",")
+	c.binds = append(c.binds, mount)
+	return c.builder
+}
+
+// Tmpfs mount a tmpfs on container path, with mount options like size=64m or mode=1777.
+func (c *createOptions[B]) Tmpfs(path string, options ...string) B {
+	if len(options) > 0 {
+		path += ":" + strings.Join(options, ",")
+	}
+	c.tmpfs = append(c.tmpfs, path)
+	return c.builder
+}
+
+// Publish container ports: [[ip:][hostPort]:]containerPort[/protocol], ports may be ranges.
+func (c *createOptions[B]) Publish(ports ...string) B {
+	c.ports = append(c.ports, ports...)
+	return c.builder
+}
+
+func (c *createOptions[B]) Network(networks ...string) B {
+	c.networks = append(c.networks, networks...)
+	return c.builder
+}
+
+func (c *createOptions[B]) AddLabels(labels ...string) B {
+	c.labels = append(c.labels, labels...)
+	return c.builder
+}
+
+func (c *createOptions[B]) AddLabelMap(labels map[string]string) B {
+	for key, value := range labels {
+		c.AddLabels(key + "=" + value)
+	}
+	return c.builder
+}
+
+func (c *createOptions[B]) Healthcheck(healthcheck Healthcheck) B {
+	c.healthcheck = &healthcheck
+	return c.builder
 }
 
 type container struct {
@@ -446,7 +848,9 @@ type container struct {
 }
 
 func (c container) Run(image string, cmdAndArgs ...string) *runner {
-	return &runner{container: c, image: image, cmdAndArgs: cmdAndArgs}
+	r := &runner{container: c}
+	r.createOptions = createOptions[*runner]{builder: r, image: image, cmdAndArgs: cmdAndArgs}
+	return r
 }
 
 func (c container) Exec(cmdAndArgs ...string) *executer {
@@ -457,53 +861,42 @@ func (c container) Stop() *stopper {
 	return &stopper{container: c}
 }
 
-func Engine() engine {
-	ok, binaryPath := selectContainerEngine()
-	if !ok {
-		err := fmt.Errorf("no container engine found in path. You must install podman or docker")
-		panic(err)
-	}
-	var provider Provider
-	if strings.Contains(binaryPath, "podman") {
-		provider = PODMAN
-	} else if strings.Contains(binaryPath, "docker") {
-		provider = DOCKER
-	}
-	return engine{provider: provider, binary: binaryPath}
+func (c container) Create(image string, cmdAndArgs ...string) *creator {
+	cr := &creator{container: c}
+	cr.createOptions = createOptions[*creator]{builder: cr, image: image, cmdAndArgs: cmdAndArgs}
+	return cr
 }
 
-func selectContainerEngine() (ok bool, binaryPath string) {
-	// Check presence of podman & docker binary
-	// Prefer to use podman
+func (c container) Start() *starter {
+	return &starter{container: c}
+}
 
-	// Check env var for a preference
-	ok, binaryPath = utilz.EnvValue(CONTAINER_ENGINE_ENV_KEY)
-	if ok {
-		return
-	}
+func (c container) Logs() *logger {
+	return &logger{container: c}
+}
 
-	// Search PATH for an existing binary
-	pCmd := cmdz.Sh("which", "podman").ErrorOnFailure(false).AddEnviron(os.Environ()...)
-	pCode, err := pCmd.BlockRun()
-	if err != nil {
-		panic(err)
-	}
-	if pCode == 0 {
-		ok = true
-		binaryPath = strings.TrimSpace(pCmd.StdoutRecord())
-		return
-	}
+func (c container) Inspect() *inspecter {
+	return &inspecter{container: c}
+}
 
-	dCmd := cmdz.Sh("which", "docker").ErrorOnFailure(false).AddEnviron(os.Environ()...)
-	dCode, err := dCmd.BlockRun()
-	if err != nil {
-		panic(err)
-	}
-	if dCode == 0 {
-		ok = true
-		binaryPath = strings.TrimSpace(dCmd.StdoutRecord())
-		return
-	}
+func (c container) Rm() *remover {
+	return &remover{container: c}
+}
 
-	return
+func (c container) Wait() *waiter {
+	return &waiter{container: c}
+}
+
+// CpTo copy srcPath from host into the container at destPath.
+func (c container) CpTo(srcPath, destPath string) *copier {
+	return &copier{container: c, src: srcPath, dest: c.name + ":" + destPath}
+}
+
+// CpFrom copy srcPath from the container into destPath on host.
+func (c container) CpFrom(srcPath, destPath string) *copier {
+	return &copier{container: c, src: c.name + ":" + srcPath, dest: destPath}
+}
+
+func (c container) Kill() *killer {
+	return &killer{container: c}
 }
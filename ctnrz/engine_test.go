@@ -4,6 +4,7 @@ import (
 	//"fmt"
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,9 +14,18 @@ var (
 	testImage = "alpine:3.16"
 )
 
+// Container engine of integration tests, skipped without a real engine. Fake engine is tested in fake_test.go.
+func detectEngine(t *testing.T) Engine {
+	e, err := Detect()
+	if err != nil || e.Provider() == FAKE {
+		t.Skip("No container engine available")
+	}
+	return e
+}
+
 func TestWaitRun(t *testing.T) {
 	expectedOut := "foo"
-	run := Engine().Container().Run(testImage, "echo", expectedOut).Rm().Executer()
+	run := detectEngine(t).Container().Run(testImage, "echo", expectedOut).Rm().Executer()
 	var outBuff bytes.Buffer
 	var errBuff bytes.Buffer
 	run.SetOutputs(&outBuff, &errBuff)
@@ -31,7 +41,7 @@ func TestWaitRun(t *testing.T) {
 
 func TestWaitRunWithEntrypoint(t *testing.T) {
 	expectedOut := "foo"
-	run := Engine().Container().Run(testImage, expectedOut).Entrypoint("echo").Rm().Executer()
+	run := detectEngine(t).Container().Run(testImage, expectedOut).Entrypoint("echo").Rm().Executer()
 	var outBuff bytes.Buffer
 	var errBuff bytes.Buffer
 	run.SetOutputs(&outBuff, &errBuff)
@@ -47,7 +57,7 @@ func TestWaitRunWithEnvArg(t *testing.T) {
 	expectedOut := "foo"
 	envArgs := make(map[string]string)
 	envArgs["var"] = expectedOut
-	run := Engine().Container().Run(testImage, "sh", "-c", "echo $var").AddEnvMap(envArgs).Rm().Executer()
+	run := detectEngine(t).Container().Run(testImage, "sh", "-c", "echo $var").AddEnvMap(envArgs).Rm().Executer()
 	var outBuff bytes.Buffer
 	var errBuff bytes.Buffer
 	run.SetOutputs(&outBuff, &errBuff)
@@ -58,3 +68,50 @@ func TestWaitRunWithEnvArg(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, exitCode)
 }
+
+func TestRunnerOptions(t *testing.T) {
+	c := testEngine.Container("foo")
+	r := c.Run("alpine", "sh").Interactive().Tty().Privileged().Init().User("bar").Userns("host").
+		Workdir("/work").Hostname("box").Restart("on-failure:3").CpuLimit(1.5).MemLimitInMb(512).
+		AddVolumes("/a:/b").Bind("/src", "/dest", "readonly").Tmpfs("/tmp", "size=64m", "mode=1777").
+		Publish("8080:80", "127.0.0.1::443/udp").Network("back").AddLabels("app=foo").AddEnvs("k=v").
+		Healthcheck(Healthcheck{Cmd: "true", Interval: 30 * time.Second, Retries: 3})
+	require.NoError(t, r.Validate())
+	assert.Equal(t, "docker run --name foo -i -t --privileged --init -u bar --userns host -w /work --hostname box"+
+		" --restart on-failure:3 --cpus 1.5 --memory 512m -v /a:/b --mount type=bind,source=/src,target=/dest,readonly"+
+		" --tmpfs /tmp:size=64m,mode=1777 -p 8080:80 -p 127.0.0.1::443/udp --network back --label app=foo -e=k=v"+
+		" --health-cmd true --health-interval 30s --health-retries 3 alpine sh", r.Executer().String())
+
+	// Explicit userns replace engine default
+	defer func(r func() bool) { rootless = r }(rootless)
+	rootless = func() bool { return true }
+	podman := engine{adapter: podmanAdapter{}, binary: "podman"}
+	assert.Equal(t, "podman run --name foo --userns keep-id alpine", podman.Container("foo").Run("alpine").Executer().String())
+	assert.Equal(t, "podman run --name foo --userns host alpine", podman.Container("foo").Run("alpine").Userns("host").Executer().String())
+}
+
+func TestRunnerValidate(t *testing.T) {
+	c := testEngine.Container("foo")
+	assert.NoError(t, c.Run("alpine").Rm().Restart("no").Validate())
+	assert.NoError(t, c.Run("alpine").AddVolumes("/data", "data:/data", "/a:/b:ro").Validate())
+
+	err := c.Run("alpine").Rm().Restart("always").Validate()
+	assert.ErrorContains(t, err, "conflict with rm")
+
+	err = testEngine.Container("-bad").Run("").Restart("sometimes").CpuLimit(-1).MemLimitInMb(-1).
+		Workdir("work").AddVolumes("a", "a:b").Tmpfs("tmp").Publish("80:70000", "8000-8001:80").
+		AddLabels("=foo").Healthcheck(Healthcheck{Retries: -1}).Validate()
+	require.Error(t, err)
+	for _, expected := range []string{"missing image", "bad container name", "bad restart policy", "negative cpu limit",
+		"negative memory limit", "workdir is not absolute", "bad volume", "tmpfs path is not absolute",
+		"bad container port", "port ranges differ", "missing key", "missing healthcheck command", "negative healthcheck"} {
+		assert.ErrorContains(t, err, expected)
+	}
+
+	// Invalid run is not executed
+	fake := NewFake()
+	_, err = fake.Engine().Container("foo").Run("").Executer().BlockRun()
+	assert.ErrorContains(t, err, "missing image")
+	assert.Empty(t, fake.Images())
+	assertStatus(t, fake.Engine(), "foo", NOT_FOUND)
+}
@@ -0,0 +1,807 @@
+package ctnrz
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxbossard/utilz/utilz"
+)
+
+// CONTAINER_ENGINE value selecting the default fake engine.
+const FAKE_ENGINE = "fake"
+
+var defaultFake = NewFake()
+
+// FakeProcess is a command executed in a fake container.
+type FakeProcess struct {
+	Container string
+	Argv      []string
+	Env       []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+}
+
+// FakeRunner execute a command of a fake container until ctx is done and return its exit code.
+type FakeRunner func(ctx context.Context, p FakeProcess) int
+
+// LocalProcess run the command of a fake container as a local process. A container without command run until stopped.
+func LocalProcess(ctx context.Context, p FakeProcess) int {
+	if len(p.Argv) == 0 {
+		<-ctx.Done()
+		return 0
+	}
+	cmd := exec.CommandContext(ctx, p.Argv[0], p.Argv[1:]...)
+	cmd.Env = append(os.Environ(), p.Env...)
+	cmd.Stdin = p.Stdin
+	cmd.Stdout = p.Stdout
+	cmd.Stderr = p.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	} else if err != nil {
+		fmt.Fprintln(p.Stderr, err)
+		return 127
+	}
+	return 0
+}
+
+// Fake is an in-process container engine keeping containers and images in memory.
+type Fake struct {
+	mu         sync.Mutex
+	runner     FakeRunner
+	containers map[string]*fakeContainer
+	images     map[string]bool
+	count      int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		runner:     LocalProcess,
+		containers: map[string]*fakeContainer{},
+		images:     map[string]bool{},
+	}
+}
+
+// Runner replace the runner of container commands, default to LocalProcess.
+func (f *Fake) Runner(runner FakeRunner) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runner = runner
+	return f
+}
+
+func (f *Fake) Engine() Engine {
+	return engine{adapter: fakeAdapter{}, binary: FAKE_ENGINE, fake: f, version: &engineVersion{}}
+}
+
+// Images return the known images sorted.
+func (f *Fake) Images() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var images []string
+	for image := range f.images {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images
+}
+
+type syncBuffer struct {
+	sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.String()
+}
+
+type fakeContainer struct {
+	id     string
+	name   string
+	image  string
+	argv   []string
+	env    []string
+	labels map[string]string
+	ports  []Port
+	user   string
+	dir    string
+	remove bool
+	count  int
+
+	state      string
+	exitCode   int
+	stopCode   int
+	created    time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	logs       syncBuffer
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func forgeId() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Parse leading flags of args. Flags listed in valued take the next arg as value unless written flag=value.
+func parseFlags(args []string, valued ...string) (flags map[string][]string, rest []string) {
+	flags = map[string][]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return flags, args[i+1:]
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return flags, args[i:]
+		}
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			flags[key] = append(flags[key], value)
+		} else if slices.Contains(valued, arg) && i+1 < len(args) {
+			flags[arg] = append(flags[arg], args[i+1])
+			i++
+		} else {
+			flags[arg] = append(flags[arg], "")
+		}
+	}
+	return flags, nil
+}
+
+func has(flags map[string][]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := flags[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func last(flags map[string][]string, names ...string) (value string) {
+	for _, name := range names {
+		if values := flags[name]; len(values) > 0 {
+			value = values[len(values)-1]
+		}
+	}
+	return
+}
+
+func all(flags map[string][]string, names ...string) (values []string) {
+	for _, name := range names {
+		values = append(values, flags[name]...)
+	}
+	return
+}
+
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// Fake engine error, result code 125 like podman and docker.
+func fakeError(c *exec.Cmd, format string, args ...any) int {
+	fmt.Fprintf(orDiscard(c.Stderr), "Error: "+format+"\n", args...)
+	return 125
+}
+
+// Handle an engine command in process. Blocking commands return once ctx is done.
+func (f *Fake) handle(ctx context.Context, c *exec.Cmd) int {
+	if len(c.Args) < 2 {
+		return fakeError(c, "missing command")
+	}
+	args := c.Args[2:]
+	switch c.Args[1] {
+	case "--version":
+		fmt.Fprintln(orDiscard(c.Stdout), "fake version 1.0.0")
+		return 0
+	case "run":
+		return f.run(ctx, c, args)
+	case "create":
+		return f.create(c, args)
+	case "start":
+		return f.start(ctx, c, args)
+	case "exec":
+		return f.exec(ctx, c, args)
+	case "stop":
+		return f.stop(c, args)
+	case "kill":
+		return f.kill(c, args)
+	case "rm":
+		return f.rm(c, args)
+	case "wait":
+		return f.wait(ctx, c, args)
+	case "logs":
+		return f.logs(ctx, c, args)
+	case "ps":
+		return f.ps(c, args)
+	case "container":
+		if len(args) > 0 && args[0] == "inspect" {
+			return f.inspect(c, args[1:])
+		}
+	case "pull":
+		return f.pull(c, args)
+	case "push":
+		return f.push(c, args)
+	case "build":
+		return f.build(c, args)
+	case "tag":
+		return f.tag(c, args)
+	}
+	return fakeError(c, "command not supported by fake engine: %s", strings.Join(c.Args[1:], " "))
+}
+
+var createValuedFlags = []string{"--name", "--entrypoint", "-u", "-v", "-e", "--userns", "-l", "--label",
+	"-p", "--network", "--mount", "--tmpfs", "--cpus", "--memory", "-w", "--hostname", "--restart",
+	"--health-cmd", "--health-interval", "--health-timeout", "--health-start-period", "--health-retries"}
+
+// Create a container from run or create args. Must be called with lock held.
+func (f *Fake) createContainer(c *exec.Cmd, flags map[string][]string, rest []string) (*fakeContainer, int) {
+	if len(rest) == 0 {
+		return nil, fakeError(c, "missing image")
+	}
+	name := last(flags, "--name")
+	if name == "" {
+		name = utilz.ForgeUuidOrPanic()
+	}
+	if _, ok := f.containers[name]; ok {
+		return nil, fakeError(c, "container name %q is already in use", name)
+	}
+	argv := rest[1:]
+	if entrypoint := last(flags, "--entrypoint"); entrypoint != "" {
+		argv = append([]string{entrypoint}, argv...)
+	}
+	labels := map[string]string{}
+	for _, label := range all(flags, "-l", "--label") {
+		k, v, _ := strings.Cut(label, "=")
+		labels[k] = v
+	}
+	var ports []Port
+	for _, spec := range all(flags, "-p") {
+		published, err := parsePublish(spec)
+		if err != nil {
+			return nil, fakeError(c, "%s", err)
+		}
+		ports = append(ports, published...)
+	}
+	f.count++
+	ctnr := &fakeContainer{
+		id:      forgeId(),
+		name:    name,
+		image:   rest[0],
+		argv:    argv,
+		env:     all(flags, "-e"),
+		labels:  labels,
+		ports:   ports,
+		user:    last(flags, "-u"),
+		dir:     last(flags, "-w"),
+		remove:  has(flags, "--rm"),
+		count:   f.count,
+		state:   "created",
+		created: time.Now(),
+	}
+	// Images are pulled on demand
+	f.images[ctnr.image] = true
+	f.containers[name] = ctnr
+	return ctnr, 0
+}
+
+// Find a container by name or id. Must be called with lock held.
+func (f *Fake) find(ref string) *fakeContainer {
+	if ctnr, ok := f.containers[ref]; ok {
+		return ctnr
+	}
+	for _, ctnr := range f.containers {
+		if ctnr.id == ref || len(ref) >= 12 && strings.HasPrefix(ctnr.id, ref) {
+			return ctnr
+		}
+	}
+	return nil
+}
+
+// Start the container command. Must be called with lock held.
+func (f *Fake) startContainer(ctnr *fakeContainer, stdin io.Reader, stdout, stderr io.Writer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctnr.state = "running"
+	ctnr.startedAt = time.Now()
+	ctnr.stopCode = 0
+	ctnr.cancel = cancel
+	ctnr.done = make(chan struct{})
+	p := FakeProcess{
+		Container: ctnr.name,
+		Argv:      ctnr.argv,
+		Env:       ctnr.env,
+		Stdin:     stdin,
+		Stdout:    io.MultiWriter(&ctnr.logs, orDiscard(stdout)),
+		Stderr:    io.MultiWriter(&ctnr.logs, orDiscard(stderr)),
+	}
+	runner := f.runner
+	done := ctnr.done
+	go func() {
+		rc := runner(ctx, p)
+		cancel()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if ctnr.stopCode != 0 {
+			rc = ctnr.stopCode
+		}
+		ctnr.exitCode = rc
+		ctnr.state = "exited"
+		ctnr.finishedAt = time.Now()
+		if ctnr.remove && f.containers[ctnr.name] == ctnr {
+			delete(f.containers, ctnr.name)
+		}
+		close(done)
+	}()
+}
+
+// Stop a running container with an exit code and wait for it to end.
+func (f *Fake) stopContainer(ctnr *fakeContainer, exitCode int) {
+	f.mu.Lock()
+	if ctnr.state != "running" {
+		f.mu.Unlock()
+		return
+	}
+	ctnr.stopCode = exitCode
+	ctnr.cancel()
+	done := ctnr.done
+	f.mu.Unlock()
+	<-done
+}
+
+// Wait for an attached container, stopping it if ctx is done as the engine client proxy signals.
+func (f *Fake) attach(ctx context.Context, ctnr *fakeContainer) int {
+	done := ctnr.done
+	f.mu.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		f.stopContainer(ctnr, 143)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return ctnr.exitCode
+}
+
+func (f *Fake) run(ctx context.Context, c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, createValuedFlags...)
+	f.mu.Lock()
+	ctnr, rc := f.createContainer(c, flags, rest)
+	if ctnr == nil {
+		f.mu.Unlock()
+		return rc
+	}
+	if has(flags, "-d") {
+		f.startContainer(ctnr, nil, nil, nil)
+		f.mu.Unlock()
+		fmt.Fprintln(orDiscard(c.Stdout), ctnr.id)
+		return 0
+	}
+	var stdin io.Reader
+	if has(flags, "-i") {
+		stdin = c.Stdin
+	}
+	f.startContainer(ctnr, stdin, c.Stdout, c.Stderr)
+	return f.attach(ctx, ctnr)
+}
+
+func (f *Fake) create(c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, createValuedFlags...)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ctnr, rc := f.createContainer(c, flags, rest)
+	if ctnr == nil {
+		return rc
+	}
+	fmt.Fprintln(orDiscard(c.Stdout), ctnr.id)
+	return 0
+}
+
+func (f *Fake) start(ctx context.Context, c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args)
+	if len(rest) != 1 {
+		return fakeError(c, "start take exactly one container")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	if ctnr == nil {
+		f.mu.Unlock()
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	if ctnr.state == "running" {
+		f.mu.Unlock()
+		fmt.Fprintln(orDiscard(c.Stdout), rest[0])
+		return 0
+	}
+	if !has(flags, "-a") {
+		f.startContainer(ctnr, nil, nil, nil)
+		f.mu.Unlock()
+		fmt.Fprintln(orDiscard(c.Stdout), rest[0])
+		return 0
+	}
+	var stdin io.Reader
+	if has(flags, "-i") {
+		stdin = c.Stdin
+	}
+	f.startContainer(ctnr, stdin, c.Stdout, c.Stderr)
+	return f.attach(ctx, ctnr)
+}
+
+func (f *Fake) exec(ctx context.Context, c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, "-u", "-e")
+	if len(rest) < 2 {
+		return fakeError(c, "exec take a container and a command")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	if ctnr == nil || ctnr.state != "running" {
+		f.mu.Unlock()
+		return fakeError(c, "container %s is not running", rest[0])
+	}
+	p := FakeProcess{
+		Container: ctnr.name,
+		Argv:      rest[1:],
+		Env:       append(append([]string(nil), ctnr.env...), all(flags, "-e")...),
+		Stdout:    orDiscard(c.Stdout),
+		Stderr:    orDiscard(c.Stderr),
+	}
+	if has(flags, "-i") {
+		p.Stdin = c.Stdin
+	}
+	runner := f.runner
+	// Stopping the container end its exec processes
+	execCtx, cancel := context.WithCancel(context.Background())
+	go func(done chan struct{}) {
+		select {
+		case <-done:
+			cancel()
+		case <-execCtx.Done():
+		}
+	}(ctnr.done)
+	f.mu.Unlock()
+	if has(flags, "-d") {
+		p.Stdout, p.Stderr = io.Discard, io.Discard
+		go func() {
+			defer cancel()
+			runner(execCtx, p)
+		}()
+		return 0
+	}
+	defer cancel()
+	// Attached exec end with the client
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	return runner(execCtx, p)
+}
+
+func (f *Fake) stop(c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args, "--time", "-t")
+	if len(rest) != 1 {
+		return fakeError(c, "stop take exactly one container")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	f.mu.Unlock()
+	if ctnr == nil {
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	// Terminated by SIGTERM
+	f.stopContainer(ctnr, 143)
+	fmt.Fprintln(orDiscard(c.Stdout), rest[0])
+	return 0
+}
+
+func (f *Fake) kill(c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, "--signal", "-s")
+	if len(rest) != 1 {
+		return fakeError(c, "kill take exactly one container")
+	}
+	signal := 9
+	if value := last(flags, "--signal", "-s"); value != "" {
+		var err error
+		if signal, err = strconv.Atoi(value); err != nil {
+			return fakeError(c, "fake engine only support numeric signals: %s", value)
+		}
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	running := ctnr != nil && ctnr.state == "running"
+	f.mu.Unlock()
+	if !running {
+		return fakeError(c, "container %s is not running", rest[0])
+	}
+	f.stopContainer(ctnr, 128+signal)
+	fmt.Fprintln(orDiscard(c.Stdout), rest[0])
+	return 0
+}
+
+func (f *Fake) rm(c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args)
+	if len(rest) != 1 {
+		return fakeError(c, "rm take exactly one container")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	running := ctnr != nil && ctnr.state == "running"
+	f.mu.Unlock()
+	if ctnr == nil {
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	if running {
+		if !has(flags, "-f") {
+			return fakeError(c, "container %s is running, stop it or force removal", rest[0])
+		}
+		f.stopContainer(ctnr, 137)
+	}
+	f.mu.Lock()
+	if f.containers[ctnr.name] == ctnr {
+		delete(f.containers, ctnr.name)
+	}
+	f.mu.Unlock()
+	fmt.Fprintln(orDiscard(c.Stdout), rest[0])
+	return 0
+}
+
+func (f *Fake) wait(ctx context.Context, c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args)
+	if len(rest) != 1 {
+		return fakeError(c, "wait take exactly one container")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	if ctnr == nil {
+		f.mu.Unlock()
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	done := ctnr.done
+	f.mu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return -1
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintln(orDiscard(c.Stdout), ctnr.exitCode)
+	return 0
+}
+
+func (f *Fake) logs(ctx context.Context, c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, "--tail", "--since")
+	if len(rest) != 1 {
+		return fakeError(c, "logs take exactly one container")
+	}
+	if has(flags, "--since", "-t") {
+		return fakeError(c, "fake engine do not support logs --since nor -t")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	if ctnr == nil {
+		f.mu.Unlock()
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	done := ctnr.done
+	f.mu.Unlock()
+	if has(flags, "-f") && done != nil {
+		// Logs are written once the container stopped
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return -1
+		}
+	}
+	logs := ctnr.logs.String()
+	if tail := last(flags, "--tail"); tail != "" {
+		n, err := strconv.Atoi(tail)
+		if err != nil {
+			return fakeError(c, "bad tail value: %s", tail)
+		}
+		lines := strings.SplitAfter(logs, "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		logs = strings.Join(lines[max(len(lines)-n, 0):], "")
+	}
+	_, _ = io.WriteString(orDiscard(c.Stdout), logs)
+	return 0
+}
+
+func (ctnr *fakeContainer) matches(filter string) bool {
+	key, value, _ := strings.Cut(filter, "=")
+	switch key {
+	case "label":
+		k, v, withValue := strings.Cut(value, "=")
+		actual, ok := ctnr.labels[k]
+		return ok && (!withValue || actual == v)
+	case "status":
+		return ctnr.state == value
+	case "ancestor":
+		return ctnr.image == value || strings.HasPrefix(ctnr.image, value+":")
+	case "name":
+		return strings.Contains(ctnr.name, value)
+	}
+	return false
+}
+
+func (f *Fake) ps(c *exec.Cmd, args []string) int {
+	flags, _ := parseFlags(args, "--format", "--filter")
+	f.mu.Lock()
+	var listed []*fakeContainer
+	for _, ctnr := range f.containers {
+		if ctnr.state != "running" && !has(flags, "-a") {
+			continue
+		}
+		matching := true
+		for _, filter := range all(flags, "--filter") {
+			matching = matching && ctnr.matches(filter)
+		}
+		if matching {
+			listed = append(listed, ctnr)
+		}
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].count < listed[j].count })
+	// podman json format
+	outputs := []map[string]any{}
+	for _, ctnr := range listed {
+		outputs = append(outputs, map[string]any{
+			"Id":      ctnr.id,
+			"Names":   []string{ctnr.name},
+			"Image":   ctnr.image,
+			"State":   ctnr.state,
+			"Labels":  ctnr.labels,
+			"Ports":   ctnr.podmanPorts(),
+			"Created": ctnr.created.Unix(),
+		})
+	}
+	f.mu.Unlock()
+	out, err := json.Marshal(outputs)
+	if err != nil {
+		return fakeError(c, "%s", err)
+	}
+	fmt.Fprintln(orDiscard(c.Stdout), string(out))
+	return 0
+}
+
+// Ports in podman ps format.
+func (ctnr *fakeContainer) podmanPorts() []podmanPort {
+	ports := []podmanPort{}
+	for _, p := range ctnr.ports {
+		ports = append(ports, podmanPort{HostIp: p.HostIp, HostPort: p.HostPort, ContainerPort: p.ContainerPort, Range: 1, Protocol: p.Protocol})
+	}
+	return ports
+}
+
+// Ports in inspect format: bindings by port/protocol.
+func (ctnr *fakeContainer) portBindings() map[string][]map[string]string {
+	bindings := map[string][]map[string]string{}
+	for _, p := range ctnr.ports {
+		key := fmt.Sprintf("%d/%s", p.ContainerPort, p.Protocol)
+		binding := map[string]string{"HostIp": p.HostIp, "HostPort": strconv.Itoa(p.HostPort)}
+		bindings[key] = append(bindings[key], binding)
+	}
+	return bindings
+}
+
+func (f *Fake) inspect(c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args, "--format", "-f")
+	if len(rest) != 1 {
+		return fakeError(c, "inspect take exactly one container")
+	}
+	f.mu.Lock()
+	ctnr := f.find(rest[0])
+	if ctnr == nil {
+		f.mu.Unlock()
+		return fakeError(c, "no such container: %s", rest[0])
+	}
+	output := map[string]any{
+		"Id":      ctnr.id,
+		"Name":    ctnr.name,
+		"Created": ctnr.created,
+		"Image":   ctnr.image,
+		"Config": map[string]any{
+			"Image":      ctnr.image,
+			"Cmd":        ctnr.argv,
+			"Env":        ctnr.env,
+			"Labels":     ctnr.labels,
+			"User":       ctnr.user,
+			"WorkingDir": ctnr.dir,
+		},
+		"NetworkSettings": map[string]any{
+			"Ports": ctnr.portBindings(),
+		},
+		"State": map[string]any{
+			"Status":     ctnr.state,
+			"Running":    ctnr.state == "running",
+			"ExitCode":   ctnr.exitCode,
+			"StartedAt":  ctnr.startedAt,
+			"FinishedAt": ctnr.finishedAt,
+		},
+	}
+	f.mu.Unlock()
+	out, err := json.Marshal([]any{output})
+	if err != nil {
+		return fakeError(c, "%s", err)
+	}
+	fmt.Fprintln(orDiscard(c.Stdout), string(out))
+	return 0
+}
+
+func (f *Fake) pull(c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args)
+	if len(rest) != 1 {
+		return fakeError(c, "pull take exactly one image")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[rest[0]] = true
+	return 0
+}
+
+func (f *Fake) push(c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args)
+	if len(rest) != 1 {
+		return fakeError(c, "push take exactly one image")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.images[rest[0]] {
+		return fakeError(c, "no such image: %s", rest[0])
+	}
+	return 0
+}
+
+func (f *Fake) build(c *exec.Cmd, args []string) int {
+	flags, rest := parseFlags(args, "-t")
+	if len(rest) != 1 {
+		return fakeError(c, "build take exactly one context")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tag := range all(flags, "-t") {
+		f.images[tag] = true
+	}
+	return 0
+}
+
+func (f *Fake) tag(c *exec.Cmd, args []string) int {
+	_, rest := parseFlags(args)
+	if len(rest) != 2 {
+		return fakeError(c, "tag take an image and a tag")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.images[rest[0]] {
+		return fakeError(c, "no such image: %s", rest[0])
+	}
+	f.images[rest[1]] = true
+	return 0
+}
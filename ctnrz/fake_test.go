@@ -0,0 +1,241 @@
+package ctnrz
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/errorz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertStatus(t *testing.T, e Engine, name string, expected Status) {
+	t.Helper()
+	status, err := e.Status(name)
+	require.NoError(t, err)
+	assert.Equal(t, expected, status)
+}
+
+func TestFake_Run(t *testing.T) {
+	fake := NewFake()
+	e := fake.Engine()
+	run := e.Container("foo").Run("alpine", "sh", "-c", "echo $var; exit 3").AddEnvs("var=bar").Executer()
+	var outBuff bytes.Buffer
+	run.SetStdout(&outBuff)
+	_, err := run.BlockRun()
+	assert.Error(t, err)
+	assert.Equal(t, 3, run.ExitCode())
+	assert.Equal(t, "bar\n", outBuff.String())
+
+	assertStatus(t, e, "foo", STOPPED)
+	details, err := e.Container("foo").Inspect().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, "exited", details.State)
+	assert.Equal(t, 3, details.ExitCode)
+	assert.Equal(t, "alpine", details.Image)
+	assert.Equal(t, []string{"sh", "-c", "echo $var; exit 3"}, details.Cmd)
+	assert.Equal(t, []string{"alpine"}, fake.Images())
+
+	// Name already in use
+	_, err = e.Container("foo").Run("alpine", "true").Executer().BlockRun()
+	assert.Error(t, err)
+
+	// Removed container
+	_, err = e.Container("bar").Run("alpine", "true").Rm().Executer().BlockRun()
+	require.NoError(t, err)
+	assertStatus(t, e, "bar", NOT_FOUND)
+}
+
+func TestFake_RunWithEntrypoint(t *testing.T) {
+	e := NewFake().Engine()
+	run := e.Container().Run("alpine", "foo").Entrypoint("echo").Rm().Executer()
+	rc, err := run.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, rc)
+	assert.Equal(t, "foo\n", run.StdoutRecord())
+}
+
+func TestFake_Runner(t *testing.T) {
+	fake := NewFake().Runner(func(ctx context.Context, p FakeProcess) int {
+		fmt.Fprintf(p.Stdout, "%s: %s", p.Container, strings.Join(p.Argv, " "))
+		return 0
+	})
+	out, err := fake.Engine().Container("foo").Run("alpine", "hello", "world").Executer().BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, 0, out)
+	logs := fake.Engine().Container("foo").Logs().Executer()
+	_, err = logs.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "foo: hello world", logs.StdoutRecord())
+}
+
+func TestFake_Lifecycle(t *testing.T) {
+	e := NewFake().Engine()
+	c := e.Container("foo")
+	_, err := c.Create("alpine").AddEnvs("k=v").Executer().BlockRun()
+	require.NoError(t, err)
+	assertStatus(t, e, "foo", STOPPED)
+	summaries, err := e.Ps().All().FilterStatus("created").Formatter().Format()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "created", summaries[0].State)
+
+	// Container without command run until stopped
+	_, err = c.Start().Executer().BlockRun()
+	require.NoError(t, err)
+	assertStatus(t, e, "foo", RUNNING)
+
+	exec := c.Exec("sh", "-c", "echo $k").Executer()
+	_, err = exec.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "v\n", exec.StdoutRecord())
+
+	summaries, err = e.Ps().Formatter().Format()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "foo", summaries[0].Name)
+	assert.Equal(t, "alpine", summaries[0].Image)
+
+	_, err = c.Stop().Executer().BlockRun()
+	require.NoError(t, err)
+	exitCode, err := c.Wait().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, 143, exitCode)
+	assertStatus(t, e, "foo", STOPPED)
+
+	// Exec in a stopped container
+	_, err = c.Exec("true").Executer().BlockRun()
+	assert.Error(t, err)
+
+	// Kill with signal
+	_, err = c.Start().Executer().BlockRun()
+	require.NoError(t, err)
+	_, err = c.Kill().Executer().BlockRun()
+	require.NoError(t, err)
+	exitCode, err = c.Wait().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, 137, exitCode)
+
+	_, err = c.Rm().Executer().BlockRun()
+	require.NoError(t, err)
+	assertStatus(t, e, "foo", NOT_FOUND)
+	_, err = c.Rm().Executer().BlockRun()
+	assert.Error(t, err)
+}
+
+func TestFake_Detach(t *testing.T) {
+	e := NewFake().Engine()
+	c := e.Container("foo")
+	_, err := c.Run("alpine", "sh", "-c", "echo foo; sleep 0.1; echo bar; exit 2").Detach().Executer().BlockRun()
+	require.NoError(t, err)
+	assertStatus(t, e, "foo", RUNNING)
+
+	// Running container cannot be removed without force
+	_, err = c.Rm().Executer().BlockRun()
+	assert.Error(t, err)
+
+	logs := c.Logs().Follow().Tail(1).Executer()
+	_, err = logs.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "bar\n", logs.StdoutRecord())
+	exitCode, err := c.Wait().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, 2, exitCode)
+}
+
+func TestFake_Filters(t *testing.T) {
+	fake := NewFake()
+	e := fake.Engine()
+	for _, name := range []string{"foo", "bar"} {
+		_, err := e.Container(name).Create("alpine:3.16").Executer().BlockRun()
+		require.NoError(t, err)
+	}
+	_, err := e.(engine).command("fake", "create", "--name", "baz", "--label", "app=baz", "busybox").BlockRun()
+	require.NoError(t, err)
+
+	summaries, err := e.Ps().All().FilterAncestor("alpine").Formatter().Format()
+	require.NoError(t, err)
+	assert.Len(t, summaries, 2)
+	summaries, err = e.Ps().All().FilterLabel("app=baz").Formatter().Format()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "baz", summaries[0].Name)
+	summaries, err = e.Ps().All().FilterName("^ba").Formatter().Format()
+	require.NoError(t, err)
+	assert.Len(t, summaries, 2)
+
+	_, err = e.Pull("debian").Executer().BlockRun()
+	require.NoError(t, err)
+	_, err = e.Tagger("debian", "my/debian").Executer().BlockRun()
+	require.NoError(t, err)
+	_, err = e.Push("my/debian").Executer().BlockRun()
+	require.NoError(t, err)
+	_, err = e.Push("unknown").Executer().BlockRun()
+	assert.Error(t, err)
+	assert.Equal(t, []string{"alpine:3.16", "busybox", "debian", "my/debian"}, fake.Images())
+}
+
+func TestFake_Selection(t *testing.T) {
+	t.Setenv(CONTAINER_ENGINE_ENV_KEY, FAKE_ENGINE)
+	e := DetectOrPanic()
+	assert.Equal(t, FAKE, e.Provider())
+	assert.Same(t, defaultFake, e.(engine).fake)
+}
+
+func TestLocalProcess(t *testing.T) {
+	var out bytes.Buffer
+	rc := LocalProcess(context.Background(), FakeProcess{Argv: []string{"sh", "-c", "cat; exit 4"}, Stdin: strings.NewReader("foo"), Stdout: &out, Stderr: io.Discard})
+	assert.Equal(t, 4, rc)
+	assert.Equal(t, "foo", out.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	LocalProcess(ctx, FakeProcess{Stdout: io.Discard, Stderr: os.Stderr})
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFake_Ports(t *testing.T) {
+	e := NewFake().Engine()
+	c := e.Container("foo")
+	_, err := c.Run("alpine", "sleep", "1").Detach().Publish("8080:80", "53/udp").Workdir("/work").Executer().BlockRun()
+	require.NoError(t, err)
+	defer c.Kill().Executer().BlockRun()
+
+	expected := []Port{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, {ContainerPort: 53, Protocol: "udp"}}
+	ctnrs, err := e.Ps().Formatter().Format()
+	require.NoError(t, err)
+	require.Len(t, ctnrs, 1)
+	assert.ElementsMatch(t, expected, ctnrs[0].Ports)
+
+	details, err := c.Inspect().Formatter().Format()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, expected, details.Ports)
+	assert.Equal(t, "/work", details.WorkingDir)
+}
+
+func TestFake_Timeout(t *testing.T) {
+	e := NewFake().Engine()
+	c := e.Container("foo")
+	_, err := c.Run("alpine", "sleep", "2").Detach().Executer().BlockRun()
+	require.NoError(t, err)
+	defer c.Kill().Executer().BlockRun()
+
+	start := time.Now()
+	_, err = c.Wait().Timeout(100 * time.Millisecond).Executer().BlockRun()
+	assert.True(t, errorz.IsTimeout(err), "expected a timeout, got: %v", err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Attached run is stopped when its client times out
+	start = time.Now()
+	_, err = e.Container("bar").Run("alpine", "sleep", "2").Timeout(100 * time.Millisecond).Executer().BlockRun()
+	assert.True(t, errorz.IsTimeout(err), "expected a timeout, got: %v", err)
+	assert.Less(t, time.Since(start), time.Second)
+	assertStatus(t, e, "bar", STOPPED)
+}
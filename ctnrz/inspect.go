@@ -0,0 +1,436 @@
+package ctnrz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mxbossard/utilz/cmdz"
+)
+
+// Port is a container port, HostPort is 0 if not published.
+type Port struct {
+	HostIp        string `json:"hostIp,omitempty"`
+	HostPort      int    `json:"hostPort,omitempty"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// ContainerSummary describe a container listed by ps.
+type ContainerSummary struct {
+	Id      string            `json:"id"`
+	Name    string            `json:"name"`
+	Names   []string          `json:"names"`
+	Image   string            `json:"image"`
+	State   string            `json:"state"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Ports   []Port            `json:"ports,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+func (c ContainerSummary) Status() Status {
+	return stateStatus(c.State)
+}
+
+// ContainerDetails describe an inspected container.
+type ContainerDetails struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Image      string            `json:"image"`
+	ImageId    string            `json:"imageId"`
+	Created    time.Time         `json:"created"`
+	State      string            `json:"state"`
+	Running    bool              `json:"running"`
+	ExitCode   int               `json:"exitCode"`
+	Pid        int               `json:"pid,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Env        []string          `json:"env,omitempty"`
+	Cmd        []string          `json:"cmd,omitempty"`
+	User       string            `json:"user,omitempty"`
+	WorkingDir string            `json:"workingDir,omitempty"`
+	Ports      []Port            `json:"ports,omitempty"`
+}
+
+func (c ContainerDetails) Status() Status {
+	return stateStatus(c.State)
+}
+
+func stateStatus(state string) Status {
+	if state == "running" {
+		return RUNNING
+	}
+	return STOPPED
+}
+
+// Normalize podman and docker container states.
+func normalizeState(state string) string {
+	state = strings.ToLower(state)
+	switch state {
+	case "configured":
+		return "created"
+	case "stopped":
+		return "exited"
+	}
+	return state
+}
+
+// Layout of docker ps CreatedAt field.
+const dockerCreatedLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// Output of ps: podman and docker disagree on the types of some fields.
+type psOutput struct {
+	Id        string          `json:"Id"`
+	Names     json.RawMessage `json:"Names"`
+	Image     string          `json:"Image"`
+	State     string          `json:"State"`
+	Labels    json.RawMessage `json:"Labels"`
+	Ports     json.RawMessage `json:"Ports"`
+	Created   json.RawMessage `json:"Created"`
+	CreatedAt string          `json:"CreatedAt"`
+}
+
+type podmanPort struct {
+	HostIp        string `json:"host_ip"`
+	ContainerPort int    `json:"container_port"`
+	HostPort      int    `json:"host_port"`
+	Range         int    `json:"range"`
+	Protocol      string `json:"protocol"`
+}
+
+// Decode a raw field as a string if it is a JSON string.
+func rawString(raw json.RawMessage) (s string, ok bool) {
+	if len(raw) == 0 || raw[0] != '"' {
+		return "", false
+	}
+	err := json.Unmarshal(raw, &s)
+	return s, err == nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// docker: "foo,bar" podman: ["foo", "bar"]
+func decodeNames(raw json.RawMessage) (names []string, err error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	if s, ok := rawString(raw); ok {
+		return strings.Split(s, ","), nil
+	}
+	err = json.Unmarshal(raw, &names)
+	return
+}
+
+// docker: "k1=v1,k2=v2" podman: {"k1": "v1", "k2": "v2"}
+func decodeLabels(raw json.RawMessage) (labels map[string]string, err error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	if s, ok := rawString(raw); ok {
+		if s == "" {
+			return nil, nil
+		}
+		labels = map[string]string{}
+		for _, label := range strings.Split(s, ",") {
+			k, v, _ := strings.Cut(label, "=")
+			labels[k] = v
+		}
+		return labels, nil
+	}
+	err = json.Unmarshal(raw, &labels)
+	return
+}
+
+// docker: "0.0.0.0:8080->80/tcp, 443/tcp" podman: [{"host_ip": "", "container_port": 80, "host_port": 8080, "range": 1, "protocol": "tcp"}]
+func decodePorts(raw json.RawMessage) ([]Port, error) {
+	if isNull(raw) {
+		return nil, nil
+	}
+	if s, ok := rawString(raw); ok {
+		return parseDockerPorts(s)
+	}
+	var podmanPorts []podmanPort
+	if err := json.Unmarshal(raw, &podmanPorts); err != nil {
+		return nil, err
+	}
+	var ports []Port
+	for _, p := range podmanPorts {
+		for i := 0; i < max(p.Range, 1); i++ {
+			port := Port{HostIp: p.HostIp, ContainerPort: p.ContainerPort + i, Protocol: p.Protocol}
+			if p.HostPort > 0 {
+				port.HostPort = p.HostPort + i
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports, nil
+}
+
+// Parse a port or a port range: "80" or "8000-8001".
+func parsePortRange(s string) (first, last int, err error) {
+	from, to, isRange := strings.Cut(s, "-")
+	first, err = strconv.Atoi(from)
+	if err != nil || !isRange {
+		return first, first, err
+	}
+	last, err = strconv.Atoi(to)
+	return
+}
+
+func parseDockerPorts(s string) (ports []Port, err error) {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		mapping, protocol, _ := strings.Cut(entry, "/")
+		var hostIp, hostPorts string
+		containerPorts := mapping
+		if host, ctnr, published := strings.Cut(mapping, "->"); published {
+			containerPorts = ctnr
+			sep := strings.LastIndex(host, ":")
+			hostIp, hostPorts = host[:sep+1], host[sep+1:]
+			hostIp = strings.Trim(strings.TrimSuffix(hostIp, ":"), "[]")
+		}
+		cFirst, cLast, err := parsePortRange(containerPorts)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse ports: [%s] ! Caused by: %w", entry, err)
+		}
+		hFirst := 0
+		if hostPorts != "" {
+			hFirst, _, err = parsePortRange(hostPorts)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse ports: [%s] ! Caused by: %w", entry, err)
+			}
+		}
+		for i := 0; i <= cLast-cFirst; i++ {
+			port := Port{HostIp: hostIp, ContainerPort: cFirst + i, Protocol: protocol}
+			if hFirst > 0 {
+				port.HostPort = hFirst + i
+			}
+			ports = append(ports, port)
+		}
+	}
+	return
+}
+
+// Parse a publish spec: [[ip:][hostPort]:]containerPort[/protocol]. A missing host port is 0.
+func parsePublish(spec string) (ports []Port, err error) {
+	mapping, protocol, hasProtocol := strings.Cut(spec, "/")
+	if !hasProtocol {
+		protocol = "tcp"
+	} else if !slices.Contains([]string{"tcp", "udp", "sctp"}, protocol) {
+		return nil, fmt.Errorf("bad protocol in published port: %q", spec)
+	}
+	var hostIp, hostPorts string
+	containerPorts := mapping
+	if sep := strings.LastIndex(mapping, ":"); sep >= 0 {
+		host := mapping[:sep]
+		containerPorts = mapping[sep+1:]
+		hostPorts = host
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			hostIp, hostPorts = strings.Trim(host[:i], "[]"), host[i+1:]
+			if net.ParseIP(hostIp) == nil {
+				return nil, fmt.Errorf("bad host ip in published port: %q", spec)
+			}
+		}
+	}
+	cFirst, cLast, err := parsePublishRange(containerPorts)
+	if err != nil {
+		return nil, fmt.Errorf("bad container port in published port: %q ! Caused by: %w", spec, err)
+	}
+	hFirst := 0
+	if hostPorts != "" {
+		var hLast int
+		hFirst, hLast, err = parsePublishRange(hostPorts)
+		if err != nil {
+			return nil, fmt.Errorf("bad host port in published port: %q ! Caused by: %w", spec, err)
+		}
+		if hLast-hFirst != cLast-cFirst {
+			return nil, fmt.Errorf("host and container port ranges differ in published port: %q", spec)
+		}
+	}
+	for i := 0; i <= cLast-cFirst; i++ {
+		port := Port{HostIp: hostIp, ContainerPort: cFirst + i, Protocol: protocol}
+		if hFirst > 0 {
+			port.HostPort = hFirst + i
+		}
+		ports = append(ports, port)
+	}
+	return
+}
+
+// Parse a port range checking ports are in 1-65535 and ordered.
+func parsePublishRange(s string) (first, last int, err error) {
+	first, last, err = parsePortRange(s)
+	if err != nil {
+		return
+	}
+	if first < 1 || last > 65535 || first > last {
+		err = fmt.Errorf("port out of range: %q", s)
+	}
+	return
+}
+
+// docker: CreatedAt "2024-01-02 03:04:05 +0000 UTC" podman: Created unix timestamp.
+func decodeCreated(created json.RawMessage, createdAt string) time.Time {
+	var unix int64
+	if err := json.Unmarshal(created, &unix); err == nil && unix > 0 {
+		return time.Unix(unix, 0)
+	}
+	if t, err := time.Parse(dockerCreatedLayout, createdAt); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+func (o psOutput) summary() (s ContainerSummary, err error) {
+	s = ContainerSummary{
+		Id:      o.Id,
+		Image:   o.Image,
+		State:   normalizeState(o.State),
+		Created: decodeCreated(o.Created, o.CreatedAt),
+	}
+	if s.Names, err = decodeNames(o.Names); err != nil {
+		return
+	}
+	if len(s.Names) > 0 {
+		s.Name = s.Names[0]
+	}
+	if s.Labels, err = decodeLabels(o.Labels); err != nil {
+		return
+	}
+	s.Ports, err = decodePorts(o.Ports)
+	return
+}
+
+// Decode ps output: a JSON array for podman, one JSON object per line for docker.
+func decodePs(rc int, stdout, stderr []byte) (summaries []ContainerSummary, err error) {
+	var outputs []psOutput
+	if trimmed := bytes.TrimSpace(stdout); len(trimmed) > 0 && trimmed[0] == '[' {
+		outputs, err = cmdz.JSON[[]psOutput]()(rc, stdout, stderr)
+	} else {
+		outputs, err = cmdz.NDJSON[psOutput]()(rc, stdout, stderr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range outputs {
+		s, err := o.summary()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decode container: [%s] ! Caused by: %w", o.Id, err)
+		}
+		summaries = append(summaries, s)
+	}
+	return
+}
+
+// Output of inspect, podman and docker share most fields.
+type inspectOutput struct {
+	Id        string    `json:"Id"`
+	Name      string    `json:"Name"`
+	Created   time.Time `json:"Created"`
+	Image     string    `json:"Image"`
+	ImageName string    `json:"ImageName"`
+	Config    struct {
+		Image      string            `json:"Image"`
+		Cmd        []string          `json:"Cmd"`
+		Env        []string          `json:"Env"`
+		Labels     map[string]string `json:"Labels"`
+		User       string            `json:"User"`
+		WorkingDir string            `json:"WorkingDir"`
+	} `json:"Config"`
+	State struct {
+		Status     string    `json:"Status"`
+		Running    bool      `json:"Running"`
+		ExitCode   int       `json:"ExitCode"`
+		Pid        int       `json:"Pid"`
+		Error      string    `json:"Error"`
+		StartedAt  time.Time `json:"StartedAt"`
+		FinishedAt time.Time `json:"FinishedAt"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIp   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+	} `json:"NetworkSettings"`
+}
+
+func (o inspectOutput) details() (d ContainerDetails, err error) {
+	d = ContainerDetails{
+		Id: o.Id,
+		// docker prefix names with a slash
+		Name:       strings.TrimPrefix(o.Name, "/"),
+		Image:      o.Config.Image,
+		ImageId:    strings.TrimPrefix(o.Image, "sha256:"),
+		Created:    o.Created,
+		State:      normalizeState(o.State.Status),
+		Running:    o.State.Running,
+		ExitCode:   o.State.ExitCode,
+		Pid:        o.State.Pid,
+		Error:      o.State.Error,
+		StartedAt:  o.State.StartedAt,
+		FinishedAt: o.State.FinishedAt,
+		Labels:     o.Config.Labels,
+		Env:        o.Config.Env,
+		Cmd:        o.Config.Cmd,
+		User:       o.Config.User,
+		WorkingDir: o.Config.WorkingDir,
+	}
+	if d.Image == "" {
+		d.Image = o.ImageName
+	}
+	for containerPort, bindings := range o.NetworkSettings.Ports {
+		portRange, protocol, _ := strings.Cut(containerPort, "/")
+		port, _, err := parsePortRange(portRange)
+		if err != nil {
+			return d, fmt.Errorf("Unable to parse port: [%s] ! Caused by: %w", containerPort, err)
+		}
+		if len(bindings) == 0 {
+			d.Ports = append(d.Ports, Port{ContainerPort: port, Protocol: protocol})
+		}
+		for _, b := range bindings {
+			hostPort, _ := strconv.Atoi(b.HostPort)
+			d.Ports = append(d.Ports, Port{HostIp: b.HostIp, HostPort: hostPort, ContainerPort: port, Protocol: protocol})
+		}
+	}
+	sortPorts(d.Ports)
+	return
+}
+
+func sortPorts(ports []Port) {
+	slices.SortFunc(ports, func(a, b Port) int {
+		if a.ContainerPort != b.ContainerPort {
+			return a.ContainerPort - b.ContainerPort
+		}
+		if a.Protocol != b.Protocol {
+			return strings.Compare(a.Protocol, b.Protocol)
+		}
+		if a.HostIp != b.HostIp {
+			return strings.Compare(a.HostIp, b.HostIp)
+		}
+		return a.HostPort - b.HostPort
+	})
+}
+
+func decodeInspect(rc int, stdout, stderr []byte) (ContainerDetails, error) {
+	outputs, err := cmdz.JSON[[]inspectOutput]()(rc, stdout, stderr)
+	if err != nil {
+		return ContainerDetails{}, err
+	}
+	if len(outputs) != 1 {
+		return ContainerDetails{}, fmt.Errorf("Expected one container inspection but got %d !", len(outputs))
+	}
+	return outputs[0].details()
+}
@@ -0,0 +1,162 @@
+package ctnrz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	podmanPsOutput = `[
+  {"Id": "aaa", "Names": ["foo"], "Image": "docker.io/library/alpine:3.16", "State": "running",
+   "Labels": {"app": "foo"}, "Created": 1704164645,
+   "Ports": [{"host_ip": "", "container_port": 80, "host_port": 8080, "range": 2, "protocol": "tcp"}]},
+  {"Id": "bbb", "Names": ["bar"], "Image": "docker.io/library/alpine:3.16", "State": "exited",
+   "Labels": null, "Created": 1704164645, "Ports": null}
+]`
+	dockerPsOutput = `{"ID":"aaa","Names":"foo","Image":"alpine:3.16","State":"running","Labels":"app=foo,tier=web","CreatedAt":"2024-01-02 03:04:05 +0000 UTC","Ports":"0.0.0.0:8080-8081->80-81/tcp, :::8080-8081->80-81/tcp, 443/udp"}
+{"ID":"bbb","Names":"bar","Image":"alpine:3.16","State":"exited","Labels":"","CreatedAt":"2024-01-02 03:04:05 +0000 UTC","Ports":""}`
+)
+
+func TestDecodePs_Podman(t *testing.T) {
+	summaries, err := decodePs(0, []byte(podmanPsOutput), nil)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	foo := summaries[0]
+	assert.Equal(t, "aaa", foo.Id)
+	assert.Equal(t, "foo", foo.Name)
+	assert.Equal(t, "running", foo.State)
+	assert.Equal(t, RUNNING, foo.Status())
+	assert.Equal(t, map[string]string{"app": "foo"}, foo.Labels)
+	assert.Equal(t, []Port{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}, {HostPort: 8081, ContainerPort: 81, Protocol: "tcp"}}, foo.Ports)
+	assert.True(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Equal(foo.Created))
+	bar := summaries[1]
+	assert.Equal(t, STOPPED, bar.Status())
+	assert.Nil(t, bar.Labels)
+	assert.Nil(t, bar.Ports)
+}
+
+func TestDecodePs_Docker(t *testing.T) {
+	summaries, err := decodePs(0, []byte(dockerPsOutput), nil)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	foo := summaries[0]
+	assert.Equal(t, "aaa", foo.Id)
+	assert.Equal(t, "foo", foo.Name)
+	assert.Equal(t, "alpine:3.16", foo.Image)
+	assert.Equal(t, RUNNING, foo.Status())
+	assert.Equal(t, map[string]string{"app": "foo", "tier": "web"}, foo.Labels)
+	assert.Equal(t, []Port{
+		{HostIp: "0.0.0.0", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+		{HostIp: "0.0.0.0", HostPort: 8081, ContainerPort: 81, Protocol: "tcp"},
+		{HostIp: "::", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+		{HostIp: "::", HostPort: 8081, ContainerPort: 81, Protocol: "tcp"},
+		{ContainerPort: 443, Protocol: "udp"},
+	}, foo.Ports)
+	assert.True(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Equal(foo.Created))
+	bar := summaries[1]
+	assert.Equal(t, "exited", bar.State)
+	assert.Nil(t, bar.Labels)
+	assert.Nil(t, bar.Ports)
+
+	summaries, err = decodePs(0, []byte(""), nil)
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+
+	_, err = decodePs(0, []byte(`{"ID":"aaa","Ports":"foo/tcp"}`), nil)
+	assert.Error(t, err)
+}
+
+func TestDecodeInspect(t *testing.T) {
+	podman := `[{"Id": "aaa", "Name": "foo", "Created": "2024-01-02T03:04:05+01:00", "Image": "def",
+		"ImageName": "docker.io/library/alpine:3.16",
+		"Config": {"Cmd": ["sleep", "5"], "Labels": {"app": "foo"}},
+		"State": {"Status": "configured", "Running": false},
+		"NetworkSettings": {"Ports": {"80/tcp": [{"HostIp": "", "HostPort": "8080"}]}}}]`
+	details, err := decodeInspect(0, []byte(podman), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", details.Name)
+	assert.Equal(t, "docker.io/library/alpine:3.16", details.Image)
+	assert.Equal(t, "def", details.ImageId)
+	assert.Equal(t, "created", details.State)
+	assert.Equal(t, []Port{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}, details.Ports)
+
+	docker := `[{"Id": "aaa", "Name": "/foo", "Created": "2024-01-02T03:04:05.123456789Z", "Image": "sha256:def",
+		"Config": {"Image": "alpine:3.16", "Cmd": null, "Labels": {}},
+		"State": {"Status": "running", "Running": true, "Pid": 42, "StartedAt": "2024-01-02T03:04:06Z"},
+		"NetworkSettings": {"Ports": {"443/udp": null, "80/tcp": [{"HostIp": "0.0.0.0", "HostPort": "8080"}]}}}]`
+	details, err = decodeInspect(0, []byte(docker), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", details.Name)
+	assert.Equal(t, "alpine:3.16", details.Image)
+	assert.Equal(t, "def", details.ImageId)
+	assert.Equal(t, RUNNING, details.Status())
+	assert.Equal(t, 42, details.Pid)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC), details.StartedAt)
+	assert.Equal(t, []Port{
+		{HostIp: "0.0.0.0", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"},
+		{ContainerPort: 443, Protocol: "udp"},
+	}, details.Ports)
+}
+
+func TestPs(t *testing.T) {
+	assert.Equal(t, "docker ps --no-trunc --format '{{json .}}' -a --filter label=app=foo --filter status=running --filter ancestor=alpine",
+		testEngine.Ps().All().FilterLabel("app=foo").FilterStatus("running").FilterAncestor("alpine").Executer().String())
+	podman := engine{adapter: podmanAdapter{}, binary: "podman"}
+	assert.Equal(t, "podman ps --no-trunc --format json", podman.Ps().Executer().String())
+
+	e := scriptEngine(t, dockerPsOutput)
+	summaries, err := e.Ps().FilterName("^b").Formatter().Format()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "bar", summaries[0].Name)
+
+	_, err = e.Ps().FilterName("(").Formatter().Format()
+	assert.Error(t, err)
+
+	status, err := e.Status("foo")
+	require.NoError(t, err)
+	assert.Equal(t, RUNNING, status)
+	status, err = e.Status("fo")
+	require.NoError(t, err)
+	assert.Equal(t, NOT_FOUND, status)
+	exists, err := e.Exists("bar")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	running, err := e.IsRunning("foo")
+	require.NoError(t, err)
+	assert.True(t, running)
+	stopped, err := e.IsStopped("bar")
+	require.NoError(t, err)
+	assert.True(t, stopped)
+
+	// Engine failures are returned, not panicked
+	broken := engine{adapter: dockerAdapter{}, binary: "/not/existing/engine"}
+	_, err = broken.Status("foo")
+	assert.Error(t, err)
+	exists, err = broken.Exists("foo")
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
+
+func TestParsePublish(t *testing.T) {
+	ports, err := parsePublish("80")
+	require.NoError(t, err)
+	assert.Equal(t, []Port{{ContainerPort: 80, Protocol: "tcp"}}, ports)
+
+	ports, err = parsePublish("127.0.0.1:8080-8081:80-81/udp")
+	require.NoError(t, err)
+	assert.Equal(t, []Port{{HostIp: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "udp"},
+		{HostIp: "127.0.0.1", HostPort: 8081, ContainerPort: 81, Protocol: "udp"}}, ports)
+
+	ports, err = parsePublish("[::1]::443")
+	require.NoError(t, err)
+	assert.Equal(t, []Port{{HostIp: "::1", ContainerPort: 443, Protocol: "tcp"}}, ports)
+
+	for _, spec := range []string{"", "foo", "0", "80/icmp", "bad:8080:80", "8080:80-81", "90-80"} {
+		_, err = parsePublish(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,264 @@
+package ctnrz
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mxbossard/utilz/cmdz"
+)
+
+type creator struct {
+	container
+	createOptions[*creator]
+}
+
+// Validate return all errors of creator options joined.
+func (c *creator) Validate() error {
+	return c.createOptions.validate("create", c.name)
+}
+
+func (c *creator) Executer() cmdz.Executer {
+	return c.createOptions.executer(c.container, "create", false, c.Validate())
+}
+
+// Rm remove the container once stopped.
+func (c *creator) Rm() *creator {
+	c.remove = true
+	return c
+}
+
+func (c *creator) Timeout(timeout time.Duration) *creator {
+	c.timeout = &timeout
+	return c
+}
+
+type logger struct {
+	container
+
+	follow     bool
+	since      string
+	tail       *int
+	timestamps bool
+}
+
+func (c *logger) Executer() cmdz.Executer {
+	params := []string{c.binary, "logs"}
+	if c.follow {
+		params = append(params, "-f")
+	}
+	if c.since != "" {
+		params = append(params, "--since", c.since)
+	}
+	if c.tail != nil {
+		params = append(params, "--tail", strconv.Itoa(*c.tail))
+	}
+	if c.timestamps {
+		params = append(params, "-t")
+	}
+	params = append(params, c.name)
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// Follow logs output until the container stopped.
+func (c *logger) Follow() *logger {
+	c.follow = true
+	return c
+}
+
+// Since show logs since a timestamp (e.g. 2013-01-02T13:23:37Z) or a relative duration (e.g. 42m).
+func (c *logger) Since(since string) *logger {
+	c.since = since
+	return c
+}
+
+// SinceTime show logs since t.
+func (c *logger) SinceTime(t time.Time) *logger {
+	return c.Since(t.Format(time.RFC3339))
+}
+
+// Tail show only the last lines of logs.
+func (c *logger) Tail(lines int) *logger {
+	c.tail = &lines
+	return c
+}
+
+func (c *logger) Timestamps() *logger {
+	c.timestamps = true
+	return c
+}
+
+func (c *logger) Timeout(timeout time.Duration) *logger {
+	c.timeout = &timeout
+	return c
+}
+
+type inspecter struct {
+	container
+}
+
+func (c *inspecter) Executer() cmdz.Executer {
+	params := []string{c.binary, "container", "inspect", c.name}
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// Formatter decode the inspection of the container.
+func (c *inspecter) Formatter() cmdz.Formatter[ContainerDetails] {
+	return cmdz.Formatted(c.Executer(), decodeInspect)
+}
+
+func (c *inspecter) Timeout(timeout time.Duration) *inspecter {
+	c.timeout = &timeout
+	return c
+}
+
+type remover struct {
+	container
+
+	force   bool
+	volumes bool
+}
+
+func (c *remover) Executer() cmdz.Executer {
+	params := []string{c.binary, "rm"}
+	if c.force {
+		params = append(params, "-f")
+	}
+	if c.volumes {
+		params = append(params, "-v")
+	}
+	params = append(params, c.name)
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// Force removal of a running container.
+func (c *remover) Force() *remover {
+	c.force = true
+	return c
+}
+
+// Volumes remove anonymous volumes of the container too.
+func (c *remover) Volumes() *remover {
+	c.volumes = true
+	return c
+}
+
+func (c *remover) Timeout(timeout time.Duration) *remover {
+	c.timeout = &timeout
+	return c
+}
+
+type waiter struct {
+	container
+}
+
+func (c *waiter) Executer() cmdz.Executer {
+	params := []string{c.binary, "wait", c.name}
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// Formatter wait for the container to stop and return its exit code.
+func (c *waiter) Formatter() cmdz.Formatter[int] {
+	return cmdz.Formatted(c.Executer(), decodeExitCode)
+}
+
+func decodeExitCode(rc int, stdout, stderr []byte) (int, error) {
+	lines, err := cmdz.Lines()(rc, stdout, stderr)
+	if err != nil {
+		return -1, err
+	}
+	if len(lines) == 0 {
+		return -1, fmt.Errorf("No container exit code returned !")
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return -1, fmt.Errorf("Unable to parse container exit code ! Caused by: %w", err)
+	}
+	return exitCode, nil
+}
+
+func (c *waiter) Timeout(timeout time.Duration) *waiter {
+	c.timeout = &timeout
+	return c
+}
+
+type copier struct {
+	container
+
+	src  string
+	dest string
+
+	followLink bool
+}
+
+func (c *copier) Executer() cmdz.Executer {
+	params := []string{c.binary, "cp"}
+	if c.followLink {
+		params = append(params, "-L")
+	}
+	params = append(params, c.src, c.dest)
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// FollowLink copy the target of a source symbolic link.
+func (c *copier) FollowLink() *copier {
+	c.followLink = true
+	return c
+}
+
+func (c *copier) Timeout(timeout time.Duration) *copier {
+	c.timeout = &timeout
+	return c
+}
+
+type killer struct {
+	container
+
+	signal syscall.Signal
+}
+
+func (c *killer) Executer() cmdz.Executer {
+	params := []string{c.binary, "kill"}
+	if c.signal != 0 {
+		params = append(params, "--signal", strconv.Itoa(int(c.signal)))
+	}
+	params = append(params, c.name)
+	e := c.command(params...)
+	if c.timeout != nil {
+		e.Timeout(*c.timeout)
+	}
+	return e
+}
+
+// Signal sent to the container, default to SIGKILL.
+func (c *killer) Signal(signal syscall.Signal) *killer {
+	c.signal = signal
+	return c
+}
+
+func (c *killer) Timeout(timeout time.Duration) *killer {
+	c.timeout = &timeout
+	return c
+}
@@ -0,0 +1,85 @@
+package ctnrz
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mxbossard/utilz/cmdz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEngine = engine{adapter: dockerAdapter{}, binary: "docker"}
+
+// Engine whose binary is a script printing output.
+func scriptEngine(t *testing.T, output string) engine {
+	script := filepath.Join(t.TempDir(), "engine")
+	err := os.WriteFile(script, []byte("#!/bin/sh\ncat <<'EOF'\n"+output+"\nEOF\n"), 0755)
+	require.NoError(t, err)
+	return engine{adapter: dockerAdapter{}, binary: script}
+}
+
+func TestLifecycleExecuters(t *testing.T) {
+	c := testEngine.Container("foo")
+	assert.Equal(t, "docker create --name foo --rm -u bar -v /a:/b -e=k=v alpine echo baz",
+		c.Create("alpine", "echo", "baz").Rm().User("bar").AddVolumes("/a:/b").AddEnvs("k=v").Executer().String())
+	assert.Equal(t, "docker start -a foo", c.Start().Attach().Executer().String())
+	assert.Equal(t, "docker logs -f --since 10m --tail 5 foo", c.Logs().Follow().Since("10m").Tail(5).Executer().String())
+	assert.Equal(t, "docker container inspect foo", c.Inspect().Executer().String())
+	assert.Equal(t, "docker rm -f -v foo", c.Rm().Force().Volumes().Executer().String())
+	assert.Equal(t, "docker wait foo", c.Wait().Executer().String())
+	assert.Equal(t, "docker cp /src foo:/dest", c.CpTo("/src", "/dest").Executer().String())
+	assert.Equal(t, "docker cp -L foo:/src /dest", c.CpFrom("/src", "/dest").FollowLink().Executer().String())
+	assert.Equal(t, "docker kill --signal 15 foo", c.Kill().Signal(syscall.SIGTERM).Executer().String())
+	assert.Equal(t, "docker kill foo", c.Kill().Executer().String())
+
+	// Create share run options and validation
+	assert.Equal(t, "docker create --name foo --init -w /work --restart always -p 8080:80 --network net --label k=v --health-cmd true alpine",
+		c.Create("alpine").Init().Workdir("/work").Restart("always").Publish("8080:80").Network("net").AddLabels("k=v").
+			Healthcheck(Healthcheck{Cmd: "true"}).Executer().String())
+	assert.ErrorContains(t, c.Create("alpine").Workdir("work").Validate(), "workdir is not absolute")
+	_, err := c.Create("").Executer().BlockRun()
+	assert.ErrorContains(t, err, "Invalid create of container foo")
+
+	defer func(r func() bool) { rootless = r }(rootless)
+	rootless = func() bool { return true }
+	podman := engine{adapter: podmanAdapter{}, binary: "podman"}
+	assert.Equal(t, "podman create --name foo --userns keep-id alpine", podman.Container("foo").Create("alpine").Executer().String())
+
+	// Builders compose as cmdz executers
+	s := cmdz.Serial(c.Create("alpine").Executer(), c.Start().Executer(), c.Rm().Force().Executer())
+	assert.Equal(t, "docker create --name foo alpine\ndocker start foo\ndocker rm -f foo", s.String())
+}
+
+func TestInspect(t *testing.T) {
+	output := `[{"Id": "abc", "Name": "/foo", "Created": "2024-01-02T03:04:05.123Z", "Image": "sha256:def",
+		"Config": {"Image": "alpine", "Cmd": ["sleep", "5"], "Labels": {"k": "v"}},
+		"State": {"Status": "exited", "Running": false, "ExitCode": 3, "FinishedAt": "2024-01-02T03:04:06Z"}}]`
+	inspect, err := scriptEngine(t, output).Container("foo").Inspect().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", inspect.Id)
+	assert.Equal(t, "foo", inspect.Name)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.UTC), inspect.Created)
+	assert.Equal(t, "alpine", inspect.Image)
+	assert.Equal(t, "def", inspect.ImageId)
+	assert.Equal(t, []string{"sleep", "5"}, inspect.Cmd)
+	assert.Equal(t, map[string]string{"k": "v"}, inspect.Labels)
+	assert.Equal(t, "exited", inspect.State)
+	assert.Equal(t, STOPPED, inspect.Status())
+	assert.Equal(t, 3, inspect.ExitCode)
+
+	_, err = scriptEngine(t, "[]").Container("foo").Inspect().Formatter().Format()
+	assert.Error(t, err)
+}
+
+func TestWait(t *testing.T) {
+	exitCode, err := scriptEngine(t, "42").Container("foo").Wait().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, 42, exitCode)
+
+	_, err = scriptEngine(t, "not a number").Container("foo").Wait().Formatter().Format()
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package ctnrz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mxbossard/utilz/serializ"
+)
+
+// RunSpec declare a container run. It can be decoded from YAML or JSON, durations are Go durations like 30s.
+type RunSpec struct {
+	Name        string            `json:"name,omitempty"`
+	Image       string            `json:"image"`
+	Command     []string          `json:"command,omitempty"`
+	Entrypoint  string            `json:"entrypoint,omitempty"`
+	Interactive bool              `json:"interactive,omitempty"`
+	Tty         bool              `json:"tty,omitempty"`
+	Rm          bool              `json:"rm,omitempty"`
+	Detach      bool              `json:"detach,omitempty"`
+	Privileged  bool              `json:"privileged,omitempty"`
+	Init        bool              `json:"init,omitempty"`
+	User        string            `json:"user,omitempty"`
+	Userns      string            `json:"userns,omitempty"`
+	Workdir     string            `json:"workdir,omitempty"`
+	Hostname    string            `json:"hostname,omitempty"`
+	Restart     string            `json:"restart,omitempty"`
+	Cpus        float32           `json:"cpus,omitempty"`
+	MemoryMb    int               `json:"memoryMb,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Volumes     []string          `json:"volumes,omitempty"`
+	Binds       []BindSpec        `json:"binds,omitempty"`
+	Tmpfs       []TmpfsSpec       `json:"tmpfs,omitempty"`
+	Ports       []string          `json:"ports,omitempty"`
+	Networks    []string          `json:"networks,omitempty"`
+	Healthcheck *HealthcheckSpec  `json:"healthcheck,omitempty"`
+	Timeout     string            `json:"timeout,omitempty"`
+}
+
+type BindSpec struct {
+	Source  string   `json:"source"`
+	Target  string   `json:"target"`
+	Options []string `json:"options,omitempty"`
+}
+
+type TmpfsSpec struct {
+	Path    string   `json:"path"`
+	Options []string `json:"options,omitempty"`
+}
+
+type HealthcheckSpec struct {
+	Cmd         string `json:"cmd"`
+	Interval    string `json:"interval,omitempty"`
+	Timeout     string `json:"timeout,omitempty"`
+	StartPeriod string `json:"startPeriod,omitempty"`
+	Retries     int    `json:"retries,omitempty"`
+}
+
+// ParseRunSpec decode a YAML or JSON spec. Unknown fields are rejected.
+func ParseRunSpec(input []byte) (spec RunSpec, err error) {
+	jsonInput, err := serializ.YamlToJson(input)
+	if err != nil {
+		return spec, fmt.Errorf("Unable to parse run spec ! Caused by: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonInput))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("Unable to decode run spec ! Caused by: %w", err)
+	}
+	return
+}
+
+func parseDuration(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s duration: %q", field, value)
+	}
+	return d, nil
+}
+
+// Map entries as KEY=VALUE sorted by key so runs are reproducible.
+func sortedEntries(m map[string]string) (entries []string) {
+	for key, value := range m {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	return
+}
+
+// Build a validated runner from the spec.
+func (e engine) RunSpec(spec RunSpec) (*runner, error) {
+	var names []string
+	if spec.Name != "" {
+		names = append(names, spec.Name)
+	}
+	r := e.Container(names...).Run(spec.Image, spec.Command...)
+	r.interactive = spec.Interactive
+	r.tty = spec.Tty
+	r.remove = spec.Rm
+	r.detach = spec.Detach
+	r.privileged = spec.Privileged
+	r.init = spec.Init
+	r.User(spec.User).Userns(spec.Userns).Entrypoint(spec.Entrypoint)
+	r.Workdir(spec.Workdir).Hostname(spec.Hostname).Restart(spec.Restart)
+	r.CpuLimit(spec.Cpus).MemLimitInMb(spec.MemoryMb)
+	r.AddEnvs(sortedEntries(spec.Env)...).AddLabels(sortedEntries(spec.Labels)...)
+	r.AddVolumes(spec.Volumes...).Publish(spec.Ports...).Network(spec.Networks...)
+	for _, b := range spec.Binds {
+		r.Bind(b.Source, b.Target, b.Options...)
+	}
+	for _, t := range spec.Tmpfs {
+		r.Tmpfs(t.Path, t.Options...)
+	}
+
+	timeout, err := parseDuration("timeout", spec.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		r.Timeout(timeout)
+	}
+	if h := spec.Healthcheck; h != nil {
+		healthcheck := Healthcheck{Cmd: h.Cmd, Retries: h.Retries}
+		if healthcheck.Interval, err = parseDuration("healthcheck interval", h.Interval); err != nil {
+			return nil, err
+		}
+		if healthcheck.Timeout, err = parseDuration("healthcheck timeout", h.Timeout); err != nil {
+			return nil, err
+		}
+		if healthcheck.StartPeriod, err = parseDuration("healthcheck start period", h.StartPeriod); err != nil {
+			return nil, err
+		}
+		r.Healthcheck(healthcheck)
+	}
+	if err = r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Build a validated runner from a YAML or JSON spec.
+func (e engine) RunYaml(input []byte) (*runner, error) {
+	spec, err := ParseRunSpec(input)
+	if err != nil {
+		return nil, err
+	}
+	return e.RunSpec(spec)
+}
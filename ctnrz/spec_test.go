@@ -0,0 +1,91 @@
+package ctnrz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const runYaml = `
+name: web
+image: nginx:1.25
+command: [nginx, -g, daemon off;]
+detach: true
+restart: unless-stopped
+memoryMb: 256
+env:
+  B: b
+  A: a
+labels:
+  app: web
+ports: ["8080:80"]
+binds:
+  - source: /srv/www
+    target: /usr/share/nginx/html
+    options: [readonly]
+tmpfs:
+  - path: /tmp
+healthcheck:
+  cmd: curl -f http://localhost
+  interval: 10s
+  retries: 3
+timeout: 1m
+`
+
+func TestParseRunSpec(t *testing.T) {
+	spec, err := ParseRunSpec([]byte(runYaml))
+	require.NoError(t, err)
+	assert.Equal(t, "web", spec.Name)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, spec.Command)
+	assert.Equal(t, 256, spec.MemoryMb)
+	assert.Equal(t, []BindSpec{{Source: "/srv/www", Target: "/usr/share/nginx/html", Options: []string{"readonly"}}}, spec.Binds)
+	require.NotNil(t, spec.Healthcheck)
+	assert.Equal(t, "10s", spec.Healthcheck.Interval)
+
+	// JSON is accepted too
+	spec, err = ParseRunSpec([]byte(`{"image": "alpine", "rm": true}`))
+	require.NoError(t, err)
+	assert.Equal(t, RunSpec{Image: "alpine", Rm: true}, spec)
+
+	_, err = ParseRunSpec([]byte("image: alpine\nport: [80]\n"))
+	assert.ErrorContains(t, err, "unknown field")
+}
+
+func TestRunSpec(t *testing.T) {
+	r, err := testEngine.RunYaml([]byte(runYaml))
+	require.NoError(t, err)
+	require.NotNil(t, r.timeout)
+	assert.Equal(t, time.Minute, *r.timeout)
+	assert.Equal(t, "docker run --name web -d --restart unless-stopped --memory 256m"+
+		" --mount type=bind,source=/srv/www,target=/usr/share/nginx/html,readonly --tmpfs /tmp -p 8080:80"+
+		" --label app=web -e=A=a -e=B=b --health-cmd 'curl -f http://localhost' --health-interval 10s --health-retries 3"+
+		" nginx:1.25 nginx -g 'daemon off;'", r.Executer().String())
+
+	// Name is generated when missing
+	r, err = testEngine.RunSpec(RunSpec{Image: "alpine"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, r.name)
+
+	_, err = testEngine.RunSpec(RunSpec{Image: "alpine", Timeout: "soon"})
+	assert.ErrorContains(t, err, "bad timeout duration")
+	_, err = testEngine.RunSpec(RunSpec{Image: "alpine", Healthcheck: &HealthcheckSpec{Cmd: "true", Interval: "often"}})
+	assert.ErrorContains(t, err, "bad healthcheck interval duration")
+	_, err = testEngine.RunSpec(RunSpec{Image: "alpine", Rm: true, Restart: "always"})
+	assert.ErrorContains(t, err, "conflict with rm")
+}
+
+func TestRunSpec_Fake(t *testing.T) {
+	e := NewFake().Engine()
+	r, err := e.RunYaml([]byte("name: foo\nimage: alpine\ncommand: [echo, bar]\nports: [\"8080:80\"]\nworkdir: /work\n"))
+	require.NoError(t, err)
+	run := r.Executer()
+	_, err = run.BlockRun()
+	require.NoError(t, err)
+	assert.Equal(t, "bar\n", run.StdoutRecord())
+
+	details, err := e.Container("foo").Inspect().Formatter().Format()
+	require.NoError(t, err)
+	assert.Equal(t, []Port{{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}}, details.Ports)
+}
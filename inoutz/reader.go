@@ -17,13 +17,14 @@ func (r *RecordingReader) Read(b []byte) (n int, err error) {
 		log.Fatalf("No nested reader configured in RecordingReader !")
 	}
 
-	n, err = r.Nested.Read(b)
-	if err != nil {
-		return
-	}
+	return recordRead(r.Nested, &r.Record, b)
+}
 
+// Read from nested reader into b, recording the bytes read into record.
+func recordRead(nested io.Reader, record io.Writer, b []byte) (n int, err error) {
+	n, err = nested.Read(b)
 	if n > 0 {
-		w, err2 := r.Record.Write(b[0:n])
+		w, err2 := record.Write(b[0:n])
 		if err2 != nil {
 			return n, err2
 		}
@@ -31,7 +32,6 @@ func (r *RecordingReader) Read(b []byte) (n int, err error) {
 			return n, fmt.Errorf("Bad byte count recorded !")
 		}
 	}
-
 	return
 }
 
@@ -0,0 +1,143 @@
+package inoutz
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Record keep the bytes written into it.
+// If MaxSize > 0, only the head and the tail of the bytes are kept, up to MaxSize bytes, the middle is elided.
+// If Spill is not nil, all the bytes are written into it too.
+type Record struct {
+	MaxSize int
+	Spill   io.Writer
+
+	head    bytes.Buffer
+	tail    []byte
+	written int64
+}
+
+func (r *Record) headMax() int {
+	return r.MaxSize / 2
+}
+
+func (r *Record) tailMax() int {
+	return r.MaxSize - r.headMax()
+}
+
+func (r *Record) Write(b []byte) (int, error) {
+	count := len(b)
+	if r.Spill != nil {
+		if _, err := r.Spill.Write(b); err != nil {
+			return 0, fmt.Errorf("Unable to spill record ! Caused by: %w", err)
+		}
+	}
+	r.written += int64(len(b))
+	if r.MaxSize <= 0 {
+		return r.head.Write(b)
+	}
+	if room := r.headMax() - r.head.Len(); room > 0 {
+		n := min(room, len(b))
+		r.head.Write(b[:n])
+		b = b[n:]
+	}
+	r.tail = append(r.tail, b...)
+	if tailMax := r.tailMax(); len(r.tail) > 2*tailMax {
+		// Drop elided bytes from time to time only
+		r.tail = append(r.tail[:0], r.tail[len(r.tail)-tailMax:]...)
+	}
+	return count, nil
+}
+
+// Len return the count of bytes written, including elided ones.
+func (r *Record) Len() int64 {
+	return r.written
+}
+
+// Elided return the count of bytes written but not kept.
+func (r *Record) Elided() int64 {
+	return r.written - int64(r.head.Len()+len(r.keptTail()))
+}
+
+func (r *Record) keptTail() []byte {
+	if tailMax := r.tailMax(); r.MaxSize > 0 && len(r.tail) > tailMax {
+		return r.tail[len(r.tail)-tailMax:]
+	}
+	return r.tail
+}
+
+// Since return the kept bytes written since offset, offset counting all written bytes.
+func (r *Record) Since(offset int64) []byte {
+	var kept []byte
+	if headLen := int64(r.head.Len()); offset < headLen {
+		kept = append(kept, r.head.Bytes()[offset:]...)
+	}
+	tail := r.keptTail()
+	tailStart := r.written - int64(len(tail))
+	if offset > tailStart {
+		tail = tail[offset-tailStart:]
+	}
+	return append(kept, tail...)
+}
+
+// Bytes return all the kept bytes, without elision marker.
+func (r *Record) Bytes() []byte {
+	return r.Since(0)
+}
+
+// String return the kept bytes with an elision marker in place of the elided bytes.
+func (r *Record) String() string {
+	elided := r.Elided()
+	if elided == 0 {
+		return string(r.Bytes())
+	}
+	return fmt.Sprintf("%s[... %d bytes elided ...]%s", r.head.String(), elided, r.keptTail())
+}
+
+func (r *Record) Reset() {
+	r.head.Reset()
+	r.tail = r.tail[:0]
+	r.written = 0
+}
+
+// CappedRecordingWriter is a RecordingWriter keeping its record in a Record, so it can be capped and spilled.
+type CappedRecordingWriter struct {
+	Nested io.Writer
+	Record Record
+}
+
+func (w *CappedRecordingWriter) Write(b []byte) (int, error) {
+	return recordWrite(&w.Record, w.Nested, b)
+}
+
+func (w *CappedRecordingWriter) Reset() {
+	w.Record.Reset()
+}
+
+func (w CappedRecordingWriter) String() string {
+	return w.Record.String()
+}
+
+// CappedRecordingReader is a RecordingReader keeping its record in a Record, so it can be capped and spilled.
+type CappedRecordingReader struct {
+	Nested io.Reader
+	Record Record
+}
+
+func (r *CappedRecordingReader) Read(b []byte) (n int, err error) {
+	if r.Nested == nil {
+		log.Fatalf("No nested reader configured in CappedRecordingReader !")
+	}
+
+	return recordRead(r.Nested, &r.Record, b)
+}
+
+func (r *CappedRecordingReader) Reset() {
+	r.Record.Reset()
+}
+
+func (r CappedRecordingReader) String() string {
+	return r.Record.String()
+}
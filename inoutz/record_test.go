@@ -0,0 +1,95 @@
+package inoutz
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_Unlimited(t *testing.T) {
+	r := Record{}
+	n, err := r.Write([]byte("foo bar"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	_, _ = r.Write([]byte(" baz"))
+	assert.Equal(t, int64(11), r.Len())
+	assert.Equal(t, int64(0), r.Elided())
+	assert.Equal(t, "foo bar baz", r.String())
+	assert.Equal(t, "bar baz", string(r.Since(4)))
+}
+
+func TestRecord_MaxSize(t *testing.T) {
+	r := Record{MaxSize: 6}
+	n, err := r.Write([]byte("abcdef"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, "abcdef", r.String())
+
+	for i := 0; i < 10; i++ {
+		n, err = r.Write([]byte("0123456789"))
+		require.NoError(t, err)
+		assert.Equal(t, 10, n)
+	}
+	assert.Equal(t, int64(106), r.Len())
+	assert.Equal(t, int64(100), r.Elided())
+	assert.Equal(t, "abc789", string(r.Bytes()))
+	assert.Equal(t, "abc[... 100 bytes elided ...]789", r.String())
+
+	assert.Equal(t, "bc789", string(r.Since(1)))
+	assert.Equal(t, "789", string(r.Since(50)))
+	assert.Equal(t, "9", string(r.Since(105)))
+	assert.Empty(t, r.Since(106))
+}
+
+func TestRecord_Spill(t *testing.T) {
+	spill := bytes.Buffer{}
+	r := Record{MaxSize: 4, Spill: &spill}
+	_, err := r.Write([]byte("foo bar baz"))
+	require.NoError(t, err)
+	assert.Equal(t, "fo[... 7 bytes elided ...]az", r.String())
+	assert.Equal(t, "foo bar baz", spill.String())
+}
+
+func TestRecord_Reset(t *testing.T) {
+	r := Record{MaxSize: 4}
+	_, _ = r.Write([]byte("foo bar baz"))
+	r.Reset()
+	assert.Equal(t, int64(0), r.Len())
+	assert.Equal(t, "", r.String())
+	_, _ = r.Write([]byte("abcdef"))
+	assert.Equal(t, "ab[... 2 bytes elided ...]ef", r.String())
+}
+
+func TestCappedRecordingWriter(t *testing.T) {
+	var nested bytes.Buffer
+	w := CappedRecordingWriter{Nested: &nested, Record: Record{MaxSize: 4}}
+	n, err := w.Write([]byte("foo bar baz"))
+	require.NoError(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, "foo bar baz", nested.String())
+	assert.Equal(t, "fo[... 7 bytes elided ...]az", w.String())
+
+	w.Reset()
+	assert.Equal(t, "", w.String())
+}
+
+func TestCappedRecordingWriter_NoNested(t *testing.T) {
+	w := CappedRecordingWriter{Record: Record{MaxSize: 4}}
+	n, err := w.Write([]byte("foo bar"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, n)
+	assert.Equal(t, "fo[... 3 bytes elided ...]ar", w.String())
+}
+
+func TestCappedRecordingReader(t *testing.T) {
+	r := CappedRecordingReader{Nested: strings.NewReader("foo bar baz"), Record: Record{MaxSize: 4}}
+	content, err := io.ReadAll(&r)
+	require.NoError(t, err)
+	assert.Equal(t, "foo bar baz", string(content))
+	assert.Equal(t, int64(11), r.Record.Len())
+	assert.Equal(t, "fo[... 7 bytes elided ...]az", r.String())
+}
@@ -107,14 +107,19 @@ type RecordingWriter struct {
 }
 
 func (w *RecordingWriter) Write(b []byte) (int, error) {
-	_, err := w.Record.Write(b)
+	return recordWrite(&w.Record, w.Nested, b)
+}
+
+// Write b into record, then into nested writer if any.
+func recordWrite(record, nested io.Writer, b []byte) (int, error) {
+	_, err := record.Write(b)
 	if err != nil {
 		return 0, err
 	}
-	if w.Nested != nil {
-		return w.Nested.Write(b)
+	if nested != nil {
+		return nested.Write(b)
 	}
-	return 0, nil
+	return len(b), nil
 }
 
 func (w *RecordingWriter) Reset() {
@@ -183,7 +188,10 @@ func (w *ProcessingStreamWriter) Write(buffer []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
-		w.Nested.Write(tmpBuffer[0:n])
+		_, err = w.Nested.Write(tmpBuffer[0:n])
+		if err != nil {
+			return 0, err
+		}
 	}
 	return written, err
 }
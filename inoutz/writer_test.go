@@ -52,6 +52,14 @@ func TestCallbackLineWriter(t *testing.T) {
 
 }
 
+func TestRecordingWriter_NoNested(t *testing.T) {
+	rw := RecordingWriter{}
+	n, err := rw.Write([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "foo", rw.String())
+}
+
 func TestRecordingWriter(t *testing.T) {
 	nested := strings.Builder{}
 	rw := RecordingWriter{}
@@ -32,4 +32,18 @@ func JsonToYaml(input []byte) ([]byte, error) {
 func JsonToYamlString(input string) (string, error) {
 	output, err := JsonToYaml([]byte(input))
 	return string(output), err
-}
\ No newline at end of file
+}
+
+// UnmarshalYaml decode yaml input into out through json, so out json tags are honoured.
+func UnmarshalYaml(input []byte, out any) error {
+	var buffer any
+	err := yaml.Unmarshal(input, &buffer)
+	if err != nil {
+		return fmt.Errorf("Unable to unmarshal yaml input ! Caused by: %w", err)
+	}
+	jsonInput, err := json.Marshal(buffer)
+	if err != nil {
+		return fmt.Errorf("Unable to convert yaml input to json ! Caused by: %w", err)
+	}
+	return json.Unmarshal(jsonInput, out)
+}
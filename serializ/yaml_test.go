@@ -55,3 +55,23 @@ func TestJsonToYamlString(t *testing.T) {
 	assert.Equal(t, expectedYaml2, res)
 
 }
+
+func TestUnmarshalYaml(t *testing.T) {
+	type item struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		Tags  []string `json:"tags"`
+	}
+	var items []item
+	err := UnmarshalYaml([]byte("- name: foo\n  count: 2\n  tags: [a, b]\n- name: bar\n"), &items)
+	require.NoError(t, err)
+	assert.Equal(t, []item{{Name: "foo", Count: 2, Tags: []string{"a", "b"}}, {Name: "bar"}}, items)
+
+	var m map[string]any
+	err = UnmarshalYaml([]byte(expectedJson1), &m)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"k1": "v1", "k2": "v2"}, m)
+
+	err = UnmarshalYaml([]byte("foo: [bar"), &m)
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -88,6 +89,39 @@ func SummaryRatio(s string, length int, leftRatio float32) string {
 	return SummaryRatioEllipsis(s, length, leftRatio, "[...]")
 }
 
+// Like SummaryRatioEllipsis but keep the string content untouched (spaces and new lines).
+// Length counts runes, so multi-byte characters are never cut.
+func ElideRatioEllipsis(s string, length int, leftRatio float32, ellipsis string) string {
+	if leftRatio < 0 {
+		leftRatio = 0
+	} else if leftRatio > 1 {
+		leftRatio = 1
+	}
+	ellipsisLen := utf8.RuneCountInString(ellipsis)
+	if length < 2*ellipsisLen {
+		ellipsis = ""
+		ellipsisLen = 0
+	}
+
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+
+	leftCount := int(float32(length-ellipsisLen) * leftRatio)
+	rightCount := length - ellipsisLen - leftCount
+
+	sb := strings.Builder{}
+	sb.WriteString(string(runes[0:leftCount]))
+	sb.WriteString(ellipsis)
+	sb.WriteString(string(runes[len(runes)-rightCount:]))
+	return sb.String()
+}
+
+func ElideRatio(s string, length int, leftRatio float32) string {
+	return ElideRatioEllipsis(s, length, leftRatio, "[...]")
+}
+
 func Summary(s string, length int) string {
 	return SummaryRatioEllipsis(s, length, 0.5, "[...]")
 }
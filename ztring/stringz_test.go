@@ -88,6 +88,21 @@ func TestSummaryRatioEllipsis(t *testing.T) {
 	assert.Equal(t, "Lom.", SummaryRatioEllipsis(multilineMsg, 4, 0.5, ellipsis))
 }
 
+func TestElideRatioEllipsis(t *testing.T) {
+	ellipsis := "[...]"
+	multilineMsg := "foofoofoo\nbarbarbar\nbazbazbaz\n"
+
+	assert.Equal(t, "", ElideRatioEllipsis("", 10, 0.5, ellipsis))
+	assert.Equal(t, "  foo  bar ", ElideRatioEllipsis("  foo  bar ", 40, 0.5, ellipsis))
+	assert.Equal(t, multilineMsg, ElideRatioEllipsis(multilineMsg, 30, 0.5, ellipsis))
+	assert.Equal(t, "foofoof[...]zbazbaz\n", ElideRatioEllipsis(multilineMsg, 20, 0.5, ellipsis))
+	assert.Equal(t, "foofo[...]bazbazbaz\n", ElideRatioEllipsis(multilineMsg, 20, 0.34, ellipsis))
+	assert.Equal(t, "fz\n", ElideRatioEllipsis(multilineMsg, 3, 0.5, ellipsis))
+	// Multi-byte runes are never cut
+	assert.Equal(t, "ééé[...]àààà", ElideRatioEllipsis("ééééééèèèèàààààà", 12, 0.5, ellipsis))
+	assert.Equal(t, "é…à", ElideRatioEllipsis("éééèèèààà", 3, 0.5, "…"))
+}
+
 func TestSplitByRegexp(t *testing.T) {
 	cases := []struct {
 		in    string